@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/queue"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/storage"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/subdomain"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/webhook"
+)
+
+type UpdateAccountRequest struct {
+	Name         *string   `json:"name"`
+	Email        *string   `json:"email"`
+	ProjectID    *string   `json:"project_id"`
+	URL          *string   `json:"url"`
+	WebhookURL   *string   `json:"webhook_url"`
+	BlockedTerms *[]string `json:"blocked_terms"`
+}
+
+const (
+	UnavailableInvalid  = "invalid"
+	UnavailableReserved = "reserved"
+	UnavailableTaken    = "taken"
+)
+
+type SubDomainAvailability struct {
+	SubDomain string `json:"sub_domain"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// checkSubDomain normalizes and validates a requested sub domain and reports
+// whether an account may register it.
+func checkSubDomain(ctx context.Context, db *connector.DB, requested string) (*SubDomainAvailability, error) {
+	res := &SubDomainAvailability{SubDomain: subdomain.Normalize(requested)}
+	if err := subdomain.Validate(res.SubDomain); err != nil {
+		res.Reason = UnavailableInvalid
+		if errors.Is(err, subdomain.ErrReserved) {
+			res.Reason = UnavailableReserved
+		}
+		res.Message = err.Error()
+		return res, nil
+	}
+	taken, err := db.DB.NewSelect().Model((*model.Account)(nil)).Where("id = ?", res.SubDomain).Exists(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if taken {
+		res.Reason = UnavailableTaken
+		res.Message = "sub domain is already taken"
+		return res, nil
+	}
+	res.Available = true
+	return res, nil
+}
+
+// accountConflictMessage names the unique column of the users table that err
+// violated, so clients never see raw MySQL error text.
+func accountConflictMessage(err error) string {
+	key, _ := connector.DuplicateEntryKey(err)
+	switch key {
+	case "PRIMARY":
+		return "Sub domain is already taken"
+	case "firebase_id":
+		return "An account already exists for this user"
+	case "email":
+		return "Email is already used by another account"
+	case "project_id":
+		return "Scrapbox project is already used by another account"
+	}
+	return "Account conflicts with an existing account"
+}
+
+func newSubDomainAvailabilityHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := checkSubDomain(r.Context(), db, r.URL.Query().Get("sub_domain"))
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// callerAccount loads the account owned by the Firebase user that
+// NewAuthMiddelware verified.
+func callerAccount(r *http.Request, db *connector.DB) (*model.Account, error) {
+	firebaseID, ok := r.Context().Value("user_id").(string)
+	if !ok || firebaseID == "" {
+		return nil, errors.New("user_id is not set")
+	}
+	var account model.Account
+	if err := db.DB.NewSelect().Model(&account).Where("firebase_id = ?", firebaseID).Scan(r.Context()); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// normalizeBlockedTerms trims terms and drops empty and case-insensitive
+// duplicate entries, keeping the first spelling.
+func normalizeBlockedTerms(terms []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		key := strings.ToLower(term)
+		if term == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, term)
+	}
+	return normalized
+}
+
+func validCrawlURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func newGetAccountHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, err := callerAccount(r, db)
+		if err != nil {
+			log.Println("Not Found Account: ", err)
+			http.Error(w, "Not Found Account: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(account)
+	})
+}
+
+// newUpdateAccountHandler changes the fields present in the request. A new
+// crawl URL or Scrapbox project queues a crawl or FAQ generation right away.
+func newUpdateAccountHandler(db *connector.DB, jobQueue *queue.Queue) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, err := callerAccount(r, db)
+		if err != nil {
+			log.Println("Not Found Account: ", err)
+			http.Error(w, "Not Found Account: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req UpdateAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+			http.Error(w, "Name must not be empty", http.StatusBadRequest)
+			return
+		}
+		if req.URL != nil && *req.URL != "" && !validCrawlURL(*req.URL) {
+			http.Error(w, "Invalid url: must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+		if req.WebhookURL != nil && *req.WebhookURL != "" && !validCrawlURL(*req.WebhookURL) {
+			http.Error(w, "Invalid webhook_url: must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+
+		urlChanged := req.URL != nil && *req.URL != account.URL
+		projectChanged := req.ProjectID != nil && *req.ProjectID != account.ProjectID
+		if req.Name != nil {
+			account.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Email != nil {
+			account.Email = strings.TrimSpace(*req.Email)
+		}
+		if req.ProjectID != nil {
+			account.ProjectID = strings.TrimSpace(*req.ProjectID)
+		}
+		if req.URL != nil {
+			account.URL = *req.URL
+		}
+		if req.WebhookURL != nil {
+			account.WebhookURL = *req.WebhookURL
+		}
+		if req.BlockedTerms != nil {
+			account.BlockedTerms = normalizeBlockedTerms(*req.BlockedTerms)
+		}
+		account.UpdatedAt = time.Now()
+
+		if _, err := db.DB.NewUpdate().Model(account).
+			Column("name", "email", "project_id", "url", "webhook_url", "blocked_terms", "updated_at").
+			WherePK().
+			Exec(r.Context()); err != nil {
+			if connector.IsDuplicateEntry(err) {
+				http.Error(w, accountConflictMessage(err), http.StatusConflict)
+				return
+			}
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if urlChanged && account.URL != "" {
+			if _, err := jobQueue.EnqueueUnique(r.Context(), model.JobTypeCrawl, account.ID, CrawlData{SubDomain: account.ID, URL: account.URL}); err != nil {
+				log.Println("Error: enqueue crawl: ", err)
+			}
+		}
+		if projectChanged {
+			if _, err := jobQueue.EnqueueUnique(r.Context(), model.JobTypeGenerateFAQ, account.ID, nil); err != nil {
+				log.Println("Error: enqueue faq generation: ", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(account)
+	})
+}
+
+// newRotateWebhookSecretHandler replaces the secret the webhooks of the account
+// are signed with. The new secret is only shown in this response.
+func newRotateWebhookSecretHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, err := callerAccount(r, db)
+		if err != nil {
+			log.Println("Not Found Account: ", err)
+			http.Error(w, "Not Found Account: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		account.WebhookSecret, err = webhook.GenerateSecret()
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		account.UpdatedAt = time.Now()
+		if _, err := db.DB.NewUpdate().Model(account).Column("webhook_secret", "updated_at").WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&CreateAccountResponse{Account: account, WebhookSecret: account.WebhookSecret})
+	})
+}
+
+func newDeleteAccountHandler(db *connector.DB, store storage.BlobStore) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, err := callerAccount(r, db)
+		if err != nil {
+			log.Println("Not Found Account: ", err)
+			http.Error(w, "Not Found Account: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := deleteAccount(r.Context(), db, store, account.ID); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// deleteAccount removes an account together with its FAQs, chat history,
+// tickets, schedules, custom domains, members, API keys, jobs and crawled
+// knowledge. Rows are deleted in one transaction first; stored objects are
+// removed afterwards on a best-effort basis, since they cannot take part in
+// the transaction.
+func deleteAccount(ctx context.Context, db *connector.DB, store storage.BlobStore, accountID string) error {
+	err := db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		sessions := tx.NewSelect().Model((*model.ChatSession)(nil)).Column("id").Where("account_id = ?", accountID)
+		if _, err := tx.NewDelete().Model((*model.ChatMessage)(nil)).Where("session_id IN (?)", sessions).Exec(ctx); err != nil {
+			return err
+		}
+		for _, m := range []any{
+			(*model.ChatSession)(nil),
+			(*model.Ticket)(nil),
+			(*model.FAQ)(nil),
+			(*model.PageLink)(nil),
+			(*model.CrawlSchedule)(nil),
+			(*model.CustomDomain)(nil),
+			(*model.Member)(nil),
+			(*model.Invitation)(nil),
+			(*model.APIKey)(nil),
+			(*model.Job)(nil),
+		} {
+			if _, err := tx.NewDelete().Model(m).Where("account_id = ?", accountID).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := tx.NewDelete().Model((*model.Account)(nil)).Where("id = ?", accountID).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	keys, err := store.List(ctx, accountID+"/")
+	if err != nil {
+		log.Println("Error: list knowledge objects of ", accountID, ": ", err)
+		return nil
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Println("Error: delete knowledge object ", key, ": ", err)
+		}
+	}
+	return nil
+}
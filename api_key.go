@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/apikey"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponse carries the key only when it is created; afterwards only
+// its prefix is known.
+type APIKeyResponse struct {
+	*model.APIKey
+	Key string `json:"key"`
+}
+
+func newListAPIKeysHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []*model.APIKey{}
+		if err := db.DB.NewSelect().Model(&keys).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Order("id DESC").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(keys)
+	})
+}
+
+func newCreateAPIKeyHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			http.Error(w, "Name must not be empty", http.StatusBadRequest)
+			return
+		}
+		scopes := []string{}
+		seen := map[string]bool{}
+		for _, scope := range req.Scopes {
+			if !model.ValidAPIKeyScope(scope) {
+				http.Error(w, "Invalid scope "+strconv.Quote(scope)+": must be read, chat or admin", http.StatusBadRequest)
+				return
+			}
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+		if len(scopes) == 0 {
+			http.Error(w, "Scopes must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		key, prefix, err := apikey.Generate()
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		createdBy, _ := r.Context().Value("user_id").(string)
+		apiKey := &model.APIKey{
+			AccountID: tenant.FromContext(r.Context()).ID,
+			Name:      name,
+			Prefix:    prefix,
+			Hash:      apikey.Hash(key),
+			Scopes:    scopes,
+			CreatedBy: createdBy,
+			CreatedAt: time.Now(),
+		}
+		if _, err := db.DB.NewInsert().Model(apiKey).Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&APIKeyResponse{APIKey: apiKey, Key: key})
+	})
+}
+
+// newRevokeAPIKeyHandler stops a key from being accepted. Revoked keys stay
+// listed so their last use can still be looked up.
+func newRevokeAPIKeyHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID, err := strconv.ParseInt(r.PathValue("keyID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Not Found API Key", http.StatusNotFound)
+			return
+		}
+		var apiKey model.APIKey
+		if err := db.DB.NewSelect().Model(&apiKey).Where("id = ?", keyID).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+			log.Println("Not Found API Key: ", err)
+			http.Error(w, "Not Found API Key", http.StatusNotFound)
+			return
+		}
+		if apiKey.RevokedAt == nil {
+			now := time.Now()
+			apiKey.RevokedAt = &now
+			if _, err := db.DB.NewUpdate().Model(&apiKey).Column("revoked_at").WherePK().Exec(r.Context()); err != nil {
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,281 @@
+package batch
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	CandidateHeading    = "heading"
+	CandidateDetails    = "details"
+	CandidateDefinition = "definition"
+	CandidateJSONLD     = "json_ld"
+)
+
+// maxAnswerLength keeps a runaway section from becoming a single answer.
+const maxAnswerLength = 4000
+
+// FAQCandidate is a question and answer found on a crawled page.
+type FAQCandidate struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Kind     string `json:"kind"`
+}
+
+var (
+	questionPrefix = regexp.MustCompile(`^(?i)(?:Q\d*\s*[.:：．)）]|Q\d*\s|質問\s*[:：])\s*`)
+	answerPrefix   = regexp.MustCompile(`^(?i)(?:A\d*\s*[.:：．)）]|回答\s*[:：])\s*`)
+	questionSuffix = regexp.MustCompile(`([?？]|(です|ます|ません|でしょう|ください)か[。．]?)$`)
+)
+
+// isQuestion reports whether text reads like a question, either by its
+// punctuation or by a leading "Q." marker.
+func isQuestion(text string) bool {
+	return questionSuffix.MatchString(text) || questionPrefix.MatchString(text)
+}
+
+func cleanQuestion(text string) string {
+	return strings.TrimSpace(questionPrefix.ReplaceAllString(collapseSpace(text), ""))
+}
+
+func cleanAnswer(text string) string {
+	text = strings.TrimSpace(answerPrefix.ReplaceAllString(strings.TrimSpace(text), ""))
+	if runes := []rune(text); len(runes) > maxAnswerLength {
+		text = string(runes[:maxAnswerLength]) + "…"
+	}
+	return text
+}
+
+// ExtractFAQCandidates collects question and answer pairs from a parsed page:
+// schema.org FAQPage JSON-LD, <details>/<summary> blocks, <dt>/<dd> pairs and
+// headings phrased as questions followed by their section.
+func ExtractFAQCandidates(node *html.Node) []*FAQCandidate {
+	var candidates []*FAQCandidate
+	seen := map[string]bool{}
+	add := func(kind, question, answer string) {
+		question = cleanQuestion(question)
+		answer = cleanAnswer(answer)
+		if question == "" || answer == "" || seen[question] {
+			return
+		}
+		seen[question] = true
+		candidates = append(candidates, &FAQCandidate{Question: question, Answer: answer, Kind: kind})
+	}
+
+	// Structured data is the site's own statement of its FAQ, so it wins over
+	// anything inferred from the markup.
+	for _, qa := range jsonLDQuestions(node) {
+		add(CandidateJSONLD, qa[0], qa[1])
+	}
+
+	root := mainContent(node)
+	if root == nil {
+		return candidates
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || isBoilerplate(c) {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Details:
+				summary, answer := detailsPair(c)
+				if isQuestion(summary) {
+					add(CandidateDetails, summary, answer)
+				}
+				continue
+			case atom.Dl:
+				for _, qa := range definitionPairs(c) {
+					if isQuestion(qa[0]) {
+						add(CandidateDefinition, qa[0], qa[1])
+					}
+				}
+				continue
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				if question := inlineText(c); isQuestion(question) {
+					add(CandidateHeading, question, sectionText(c))
+				}
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return candidates
+}
+
+func detailsPair(details *html.Node) (string, string) {
+	var summary string
+	var body []*html.Node
+	for c := details.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Summary && summary == "" {
+			summary = inlineText(c)
+			continue
+		}
+		body = append(body, c)
+	}
+	var b strings.Builder
+	renderNodes(&b, body)
+	return summary, tidyMarkdown(b.String())
+}
+
+// definitionPairs pairs each <dt> with the <dd> elements that follow it. Items
+// wrapped in <div>, as the HTML spec allows, are unwrapped.
+func definitionPairs(dl *html.Node) [][2]string {
+	var pairs [][2]string
+	var term string
+	var b strings.Builder
+	flush := func() {
+		if term != "" {
+			pairs = append(pairs, [2]string{term, tidyMarkdown(b.String())})
+		}
+		term = ""
+		b.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Dt:
+				flush()
+				term = inlineText(c)
+			case atom.Dd:
+				renderNodes(&b, children(c))
+			case atom.Div:
+				walk(c)
+			}
+		}
+	}
+	walk(dl)
+	flush()
+	return pairs
+}
+
+// sectionText renders the siblings that follow a heading up to the next heading
+// of the same or a higher level.
+func sectionText(heading *html.Node) string {
+	level := heading.Data[1]
+	var section []*html.Node
+outer:
+	for c := heading.NextSibling; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			switch c.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				if c.Data[1] <= level {
+					break outer
+				}
+			}
+		}
+		section = append(section, c)
+	}
+	var b strings.Builder
+	renderNodes(&b, section)
+	return tidyMarkdown(b.String())
+}
+
+func children(node *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+func tidyMarkdown(s string) string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
+
+// jsonLDQuestions returns the questions and accepted answers of every
+// schema.org FAQPage embedded as JSON-LD, including ones nested in @graph.
+func jsonLDQuestions(node *html.Node) [][2]string {
+	var pairs [][2]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Script {
+			if strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/ld+json") {
+				var data any
+				if err := json.Unmarshal([]byte(textContent(n)), &data); err == nil {
+					pairs = append(pairs, faqPageQuestions(data)...)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return pairs
+}
+
+func faqPageQuestions(data any) [][2]string {
+	var pairs [][2]string
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			pairs = append(pairs, faqPageQuestions(item)...)
+		}
+	case map[string]any:
+		if hasSchemaType(v, "FAQPage") {
+			for _, entity := range asList(v["mainEntity"]) {
+				question, ok := entity.(map[string]any)
+				if !ok || !hasSchemaType(question, "Question") {
+					continue
+				}
+				name, _ := question["name"].(string)
+				for _, answer := range asList(question["acceptedAnswer"]) {
+					if a, ok := answer.(map[string]any); ok {
+						text, _ := a["text"].(string)
+						pairs = append(pairs, [2]string{name, htmlToMarkdown(text)})
+						break
+					}
+				}
+			}
+		}
+		if graph, ok := v["@graph"]; ok {
+			pairs = append(pairs, faqPageQuestions(graph)...)
+		}
+	}
+	return pairs
+}
+
+func hasSchemaType(v map[string]any, name string) bool {
+	for _, t := range asList(v["@type"]) {
+		if s, ok := t.(string); ok && (s == name || strings.HasSuffix(s, "/"+name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func asList(v any) []any {
+	if list, ok := v.([]any); ok {
+		return list
+	}
+	if v == nil {
+		return nil
+	}
+	return []any{v}
+}
+
+// htmlToMarkdown renders the HTML fragments JSON-LD answers commonly contain.
+func htmlToMarkdown(s string) string {
+	if !strings.Contains(s, "<") {
+		return strings.TrimSpace(s)
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"})
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	var b strings.Builder
+	renderNodes(&b, nodes)
+	return tidyMarkdown(b.String())
+}
@@ -1,15 +1,23 @@
 package batch
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/storage"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 )
@@ -23,23 +31,6 @@ type HTML struct {
 	Body string
 }
 
-type BucketBasics struct {
-	S3Client *s3.Client
-}
-
-func (basics BucketBasics) UploadFile(bucketName string, objectKey string, fileContent io.Reader) error {
-	_, err := basics.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-		Body:   fileContent,
-	})
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func newAnchor(node *html.Node) *Anchor {
 	var buff bytes.Buffer
 	for c := node.FirstChild; c != nil; c = c.NextSibling {
@@ -71,61 +62,654 @@ func findAnchors(node *html.Node, collection *[]*Anchor) {
 	}
 }
 
-func CrawlKnowledge(url, bucketName, objectKey string, s3Client *s3.Client) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+const (
+	SkipOutOfScope   = "out_of_scope"
+	SkipDisallowed   = "robots_disallowed"
+	SkipMaxDepth     = "max_depth"
+	SkipMaxPages     = "max_pages"
+	SkipFetchError   = "fetch_error"
+	SkipBadStatus    = "bad_status"
+	SkipUnsupported  = "unsupported_content_type"
+	SkipTooLarge     = "too_large"
+	SkipExtractError = "extract_error"
+	SkipDuplicate    = "duplicate_canonical"
+	SkipRedirect     = "redirect_blocked"
+)
+
+const (
+	maxSitemapFetches = 10
+	maxRedirects      = 10
+	// maxRobotsSize follows the 500 KiB minimum RFC 9309 asks crawlers to parse.
+	maxRobotsSize = 500 << 10
+)
+
+type SkippedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// CrawledPage is a fetched page. URL is the URL that was requested, while
+// Canonical is the rel=canonical URL of the page or else the URL the request
+// ended up at after redirects.
+type CrawledPage struct {
+	URL          string
+	Canonical    string
+	ContentType  string
+	Depth        int
+	Document     *Document
+	Links        []string
+	FAQs         []*FAQCandidate
+	FetchedAt    time.Time
+	ETag         string
+	LastModified string
+	// NotModified is set when the server answered a conditional request with 304.
+	NotModified bool
+}
+
+type CrawlResult struct {
+	Pages   []*CrawledPage
+	Skipped []*SkippedURL
+}
+
+func (r *CrawlResult) skip(u, reason string) {
+	r.Skipped = append(r.Skipped, &SkippedURL{URL: u, Reason: reason})
+}
+
+type crawlItem struct {
+	url   string
+	depth int
+	delay time.Duration
+}
+
+// Crawler walks a site breadth-first from a seed URL, staying under the seed's
+// host and path prefix and honouring robots.txt. Up to cfg.Concurrency pages
+// are fetched at once, while requests to a single host are spaced by
+// cfg.HostInterval or the robots.txt Crawl-delay, whichever is longer.
+type Crawler struct {
+	cfg      *config.CrawlerConfig
+	client   *http.Client
+	robots   map[string]*Robots
+	previous map[string]*ManifestEntry
+
+	mu        sync.Mutex
+	nextFetch map[string]time.Time
+}
+
+func NewCrawler(cfg *config.CrawlerConfig) *Crawler {
+	return &Crawler{
+		cfg:       cfg,
+		client:    newCrawlerClient(cfg),
+		robots:    make(map[string]*Robots),
+		previous:  make(map[string]*ManifestEntry),
+		nextFetch: make(map[string]time.Time),
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+}
+
+// crawlScopeKey carries the *crawlScope of a page fetch in its request context
+// so that redirects can be checked against it.
+type crawlScopeKey struct{}
+
+type crawlScope struct {
+	url    *url.URL
+	robots *Robots
+}
+
+func newCrawlerClient(cfg *config.CrawlerConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.Timeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   max(cfg.Concurrency, 2),
+	}
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			req.Header.Set("User-Agent", cfg.UserAgent)
+			// A redirect must not lead a page fetch off the site or into a
+			// path robots.txt disallows.
+			if scope, ok := req.Context().Value(crawlScopeKey{}).(*crawlScope); ok {
+				if !inScope(scope.url, req.URL) || !scope.robots.Allowed(cfg.UserAgent, req.URL.RequestURI()) {
+					return http.ErrUseLastResponse
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// SetPrevious makes the crawler send conditional requests for pages indexed by
+// an earlier crawl.
+func (c *Crawler) SetPrevious(m *Manifest) {
+	for _, entry := range m.Pages {
+		if entry.Status != PageStatusIndexed {
+			continue
+		}
+		key := entry.RequestURL
+		if key == "" {
+			key = entry.URL
+		}
+		c.previous[key] = entry
+	}
+}
+
+func (c *Crawler) Crawl(ctx context.Context, seed string) (*CrawlResult, error) {
+	scope, err := url.Parse(seed)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !scope.IsAbs() {
+		return nil, fmt.Errorf("seed url %q is not absolute", seed)
 	}
-	buf := bytes.NewBuffer(body)
-	parsed := buf.String()
-	r := strings.NewReader(parsed)
 
-	htmls := make(map[string]*HTML)
+	scope, _ = url.Parse(NormalizeURL(scope))
+	result := &CrawlResult{}
+	seen := map[string]bool{}
+	canonicals := map[string]bool{}
+	var queue []crawlItem
 
-	htmls[url] = &HTML{parsed}
+	enqueue := func(raw string, depth int) {
+		u, err := url.Parse(raw)
+		if err != nil || !u.IsAbs() {
+			return
+		}
+		key := NormalizeURL(u)
+		u, _ = url.Parse(key)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if !inScope(scope, u) {
+			result.skip(key, SkipOutOfScope)
+			return
+		}
+		if depth > c.cfg.MaxDepth {
+			result.skip(key, SkipMaxDepth)
+			return
+		}
+		queue = append(queue, crawlItem{url: key, depth: depth})
+	}
 
-	node, err := html.Parse(r)
-	if err != nil {
-		return err
+	enqueue(seed, 0)
+	for _, u := range c.sitemapURLs(ctx, scope) {
+		enqueue(u, 1)
 	}
+	// Pages are fetched with the scope in their context; robots.txt and
+	// sitemap requests are not.
+	visitCtx := context.WithValue(ctx, crawlScopeKey{}, &crawlScope{url: scope, robots: c.robotsFor(ctx, scope)})
 
-	var collection []*Anchor
-	findAnchors(node, &collection)
+	concurrency := max(c.cfg.Concurrency, 1)
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	for _, a := range collection {
-		fmt.Println(a.Text, ":", a.Href)
-		if strings.Contains(a.Href, url) {
-			if _, ok := htmls[a.Href]; !ok {
-				resp, err := http.Get(a.Href)
-				if err != nil {
-					return err
+		// Pick the next batch sequentially so robots.txt lookups, duplicate
+		// checks and the page budget stay deterministic, then fetch the batch
+		// concurrently.
+		var batch []crawlItem
+		for len(queue) > 0 && len(batch) < concurrency {
+			if c.cfg.MaxPages > 0 && len(result.Pages)+len(batch) >= c.cfg.MaxPages {
+				if len(batch) > 0 {
+					break
 				}
-				defer resp.Body.Close()
-				body, err := io.ReadAll(resp.Body)
-				if err != nil {
-					return err
+				for _, item := range queue {
+					result.skip(item.url, SkipMaxPages)
 				}
-				buf := bytes.NewBuffer(body)
-				parsed := buf.String()
-				htmls[a.Href] = &HTML{parsed}
+				queue = nil
+				break
+			}
+			item := queue[0]
+			queue = queue[1:]
+			if canonicals[item.url] {
+				result.skip(item.url, SkipDuplicate)
+				continue
+			}
+			u, _ := url.Parse(item.url)
+			robots := c.robotsFor(ctx, u)
+			if !robots.Allowed(c.cfg.UserAgent, u.RequestURI()) {
+				result.skip(item.url, SkipDisallowed)
+				continue
+			}
+			item.delay = robots.CrawlDelay(c.cfg.UserAgent)
+			batch = append(batch, item)
+		}
+
+		outcomes := make([]*crawlOutcome, len(batch))
+		var wg sync.WaitGroup
+		for i, item := range batch {
+			wg.Add(1)
+			go func(i int, item crawlItem) {
+				defer wg.Done()
+				// A parser bug on one hostile page must not take the whole
+				// process down with it.
+				defer func() {
+					if p := recover(); p != nil {
+						log.Println("crawl: ", item.url, ": panic: ", p)
+						outcomes[i] = &crawlOutcome{reason: SkipExtractError}
+					}
+				}()
+				outcomes[i] = c.visit(visitCtx, item)
+			}(i, item)
+		}
+		wg.Wait()
+
+		for i, outcome := range outcomes {
+			item := batch[i]
+			if outcome.reason != "" {
+				result.skip(item.url, outcome.reason)
+				continue
+			}
+			page := outcome.page
+			if canonicals[page.Canonical] {
+				result.skip(item.url, SkipDuplicate)
+				continue
+			}
+			canonicals[page.Canonical] = true
+			seen[page.Canonical] = true
+			result.Pages = append(result.Pages, page)
+			for _, link := range page.Links {
+				enqueue(link, item.depth+1)
+			}
+		}
+	}
+	return result, nil
+}
+
+type crawlOutcome struct {
+	page   *CrawledPage
+	reason string
+}
+
+// visit fetches a single URL and extracts its document and links. It runs
+// concurrently with other visits and must not touch the crawl state.
+func (c *Crawler) visit(ctx context.Context, item crawlItem) *crawlOutcome {
+	u, _ := url.Parse(item.url)
+	if err := c.wait(ctx, u.Host, item.delay); err != nil {
+		return &crawlOutcome{reason: SkipFetchError}
+	}
+
+	fetchedAt := time.Now().UTC()
+	prev := c.previous[item.url]
+	fetched, reason, err := c.fetch(ctx, item.url, prev)
+	if err != nil {
+		log.Println("crawl: ", item.url, ": ", err)
+	}
+	if reason != "" {
+		return &crawlOutcome{reason: reason}
+	}
+
+	// After a redirect the document lives at, and its relative links resolve
+	// against, the final URL rather than the requested one.
+	final := fetched.url
+	page := &CrawledPage{
+		URL:          item.url,
+		Canonical:    NormalizeURL(final),
+		Depth:        item.depth,
+		FetchedAt:    fetchedAt,
+		ContentType:  fetched.kind,
+		ETag:         fetched.etag,
+		LastModified: fetched.lastModified,
+	}
+	switch {
+	case fetched.notModified:
+		// The server confirmed the page is unchanged, so reuse what the
+		// previous crawl learned about it instead of downloading it again.
+		page.NotModified = true
+		page.Canonical = prev.URL
+		page.ContentType = prev.ContentType
+		page.Document = &Document{URL: prev.URL, Title: prev.Title}
+		page.Links = prev.Links
+		page.FAQs = prev.FAQs
+	case fetched.kind != ContentTypeHTML:
+		doc, err := extractDocument(fetched.kind, fetched.body, fetched.contentType, final, c.cfg.MaxDocumentSize)
+		if err != nil {
+			log.Println("crawl: ", item.url, ": ", err)
+			return &crawlOutcome{reason: SkipExtractError}
+		}
+		page.Document = doc
+	default:
+		node, err := html.Parse(bytes.NewReader(fetched.body))
+		if err != nil {
+			page.Document = &Document{URL: page.Canonical}
+			break
+		}
+		base := documentBase(node, final)
+		if canonical := canonicalURL(node, base); canonical != "" {
+			page.Canonical = canonical
+		}
+		page.Document = ExtractDocument(node, page.Canonical)
+		page.FAQs = ExtractFAQCandidates(node)
+
+		var collection []*Anchor
+		findAnchors(node, &collection)
+		for _, a := range collection {
+			if href, ok := resolveHref(base, a.Href); ok {
+				page.Links = append(page.Links, href)
 			}
 		}
 	}
+	return &crawlOutcome{page: page}
+}
 
-	content := ""
-	for _, html := range htmls {
-		content += html.Body + "¥n"
+func inScope(scope, u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
 	}
-	fileContent := strings.NewReader(content)
-	bucketBasics := BucketBasics{S3Client: s3Client}
-	if err := bucketBasics.UploadFile(bucketName, objectKey, fileContent); err != nil {
-		return err
+	if !strings.EqualFold(u.Host, scope.Host) {
+		return false
 	}
+	// Help pages often link to manuals stored elsewhere on the same host.
+	return strings.HasPrefix(u.Path, scope.Path) || isDocumentURL(u)
+}
 
+func (c *Crawler) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+	if err := c.wait(ctx, req.URL.Host, 0); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+// closeBody drains a little of what is left of the body before closing it so
+// the connection can be reused for the next request to the same host.
+func closeBody(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 4<<10))
+	body.Close()
+}
+
+// limitReader returns r unchanged when n is not positive.
+func limitReader(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+	return io.LimitReader(r, n)
+}
+
+type fetchResult struct {
+	// url is where the request ended up after redirects.
+	url          *url.URL
+	kind         string
+	contentType  string
+	body         []byte
+	etag         string
+	lastModified string
+	notModified  bool
+}
+
+// fetch returns the response body and its content kind, or a skip reason when
+// the URL cannot be used. When prev is set the request is made conditional on
+// its ETag and Last-Modified.
+func (c *Crawler) fetch(ctx context.Context, rawURL string, prev *ManifestEntry) (*fetchResult, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, SkipFetchError, err
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, SkipFetchError, err
+	}
+	defer closeBody(resp.Body)
+
+	fetched := &fetchResult{
+		url:          resp.Request.URL,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		fetched.notModified = true
+		if fetched.etag == "" {
+			fetched.etag = prev.ETag
+		}
+		if fetched.lastModified == "" {
+			fetched.lastModified = prev.LastModified
+		}
+		return fetched, "", nil
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return nil, SkipRedirect, fmt.Errorf("redirect to %s not followed", resp.Header.Get("Location"))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, SkipBadStatus, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	fetched.contentType = resp.Header.Get("Content-Type")
+	reader := bufio.NewReader(resp.Body)
+	sniff, _ := reader.Peek(512)
+	fetched.kind = contentKind(fetched.contentType, rawURL, sniff)
+	if fetched.kind == "" {
+		return nil, SkipUnsupported, nil
+	}
+
+	limit := c.cfg.MaxBodySize
+	if fetched.kind != ContentTypeHTML {
+		limit = c.cfg.MaxDocumentSize
+	}
+	var body []byte
+	if limit <= 0 {
+		body, err = io.ReadAll(reader)
+	} else {
+		if resp.ContentLength > limit {
+			return nil, SkipTooLarge, nil
+		}
+		body, err = io.ReadAll(io.LimitReader(reader, limit+1))
+		if err == nil && int64(len(body)) > limit {
+			return nil, SkipTooLarge, nil
+		}
+	}
+	if err != nil {
+		return nil, SkipFetchError, err
+	}
+	fetched.body = body
+	return fetched, "", nil
+}
+
+func (c *Crawler) robotsFor(ctx context.Context, u *url.URL) *Robots {
+	if robots, ok := c.robots[u.Host]; ok {
+		return robots
+	}
+	robots := &Robots{}
+	resp, err := c.get(ctx, u.Scheme+"://"+u.Host+"/robots.txt")
+	if err == nil {
+		if resp.StatusCode == http.StatusOK {
+			robots = parseRobots(io.LimitReader(resp.Body, maxRobotsSize))
+		}
+		closeBody(resp.Body)
+	}
+	c.robots[u.Host] = robots
+	return robots
+}
+
+// wait blocks until the next request to host is allowed. Each caller reserves
+// its own slot, so concurrent fetches to one host are spaced out rather than
+// released together.
+func (c *Crawler) wait(ctx context.Context, host string, delay time.Duration) error {
+	interval := max(c.cfg.HostInterval, delay)
+
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.nextFetch[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextFetch[host] = slot.Add(interval)
+	c.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
 	return nil
 }
+
+// sitemapURLs collects page URLs from the sitemaps listed in robots.txt,
+// falling back to /sitemap.xml. Sitemap indexes are followed.
+func (c *Crawler) sitemapURLs(ctx context.Context, scope *url.URL) []string {
+	pending := c.robotsFor(ctx, scope).Sitemaps
+	if len(pending) == 0 {
+		pending = []string{scope.Scheme + "://" + scope.Host + "/sitemap.xml"}
+	}
+
+	var urls []string
+	fetched := map[string]bool{}
+	for len(pending) > 0 && len(fetched) < maxSitemapFetches {
+		loc := pending[0]
+		pending = pending[1:]
+		if fetched[loc] {
+			continue
+		}
+		fetched[loc] = true
+
+		resp, err := c.get(ctx, loc)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			closeBody(resp.Body)
+			continue
+		}
+		sitemap, err := parseSitemap(limitReader(resp.Body, c.cfg.MaxDocumentSize))
+		closeBody(resp.Body)
+		if err != nil {
+			log.Println("crawl: sitemap ", loc, ": ", err)
+			continue
+		}
+		urls = append(urls, sitemap.URLs...)
+		pending = append(pending, sitemap.Sitemaps...)
+	}
+	return urls
+}
+
+// CrawlKnowledge crawls seed and stores one Markdown object per page under
+// <prefix>/crawls/<version>/pages/, together with a manifest of the run. The
+// manifest is also written to <prefix>/manifest.json as the latest crawl, and
+// the next run uses it to send conditional requests and skip unchanged pages.
+func CrawlKnowledge(ctx context.Context, seed, prefix string, store storage.BlobStore, cfg *config.CrawlerConfig) (*Manifest, error) {
+	latestKey := prefix + "/" + ManifestFileName
+	previous, err := loadManifest(ctx, store, latestKey)
+	if err != nil {
+		return nil, err
+	}
+
+	crawler := NewCrawler(cfg)
+	indexed := map[string]*ManifestEntry{}
+	if previous != nil {
+		crawler.SetPrevious(previous)
+		for _, entry := range previous.Pages {
+			if entry.Status == PageStatusIndexed {
+				indexed[entry.URL] = entry
+			}
+		}
+	}
+
+	result, err := crawler.Crawl(ctx, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	version := now.Format("20060102T150405Z")
+	versionPrefix := prefix + "/crawls/" + version
+	manifest := &Manifest{
+		SeedURL:   seed,
+		Version:   version,
+		Prefix:    versionPrefix,
+		CreatedAt: now,
+	}
+
+	current := map[string]bool{}
+	for _, page := range result.Pages {
+		current[page.Canonical] = true
+		prev := indexed[page.Canonical]
+		entry := &ManifestEntry{
+			URL:          page.Canonical,
+			RequestURL:   page.URL,
+			Title:        page.Document.Title,
+			ContentType:  page.ContentType,
+			ETag:         page.ETag,
+			LastModified: page.LastModified,
+			Links:        page.Links,
+			FAQs:         page.FAQs,
+			FetchedAt:    &page.FetchedAt,
+			Status:       PageStatusIndexed,
+		}
+
+		if page.NotModified && prev != nil {
+			entry.ObjectKey = prev.ObjectKey
+			entry.ContentHash = prev.ContentHash
+			entry.Change = ChangeUnchanged
+		} else {
+			content := page.Document.Markdown()
+			entry.ContentHash = contentHash(content)
+			switch {
+			case prev != nil && prev.ContentHash == entry.ContentHash:
+				entry.ObjectKey = prev.ObjectKey
+				entry.Change = ChangeUnchanged
+			default:
+				entry.ObjectKey = versionPrefix + "/pages/" + pageObjectName(page.Canonical)
+				if err := store.Put(ctx, entry.ObjectKey, strings.NewReader(content)); err != nil {
+					return nil, err
+				}
+				entry.Change = ChangeAdded
+				if prev != nil {
+					entry.Change = ChangeChanged
+				}
+			}
+		}
+
+		switch entry.Change {
+		case ChangeAdded:
+			manifest.Changes.Added = append(manifest.Changes.Added, entry.URL)
+		case ChangeChanged:
+			manifest.Changes.Changed = append(manifest.Changes.Changed, entry.URL)
+		case ChangeUnchanged:
+			manifest.Changes.Unchanged = append(manifest.Changes.Unchanged, entry.URL)
+		}
+		manifest.Pages = append(manifest.Pages, entry)
+	}
+	for u := range indexed {
+		if !current[u] {
+			manifest.Changes.Removed = append(manifest.Changes.Removed, u)
+		}
+	}
+	sort.Strings(manifest.Changes.Removed)
+	for _, s := range result.Skipped {
+		log.Println("crawl: skipped ", s.URL, ": ", s.Reason)
+		manifest.Pages = append(manifest.Pages, &ManifestEntry{URL: s.URL, Status: s.Reason})
+	}
+
+	marshaled, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{versionPrefix + "/" + ManifestFileName, latestKey} {
+		if err := store.Put(ctx, key, bytes.NewReader(marshaled)); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,162 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+)
+
+func TestCrawlRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>elsewhere</body></html>")
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /help/private\n")
+	})
+	mux.HandleFunc("/help/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>
+			<a href="/help/moved">moved</a>
+			<a href="/help/to-private">private</a>
+			<a href="/help/to-other">other</a>
+			<a href="/help/to-outside">outside</a>
+		</body></html>`)
+	})
+	mux.HandleFunc("/help/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/help/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/help/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>new page</body></html>")
+	})
+	mux.HandleFunc("/help/to-private", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/help/private/secret", http.StatusFound)
+	})
+	mux.HandleFunc("/help/private/secret", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("followed a redirect into a path disallowed by robots.txt")
+	})
+	mux.HandleFunc("/help/to-other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/", http.StatusFound)
+	})
+	mux.HandleFunc("/help/to-outside", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/outside", http.StatusFound)
+	})
+	mux.HandleFunc("/outside", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("followed a redirect out of the crawl scope")
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	crawler := NewCrawler(&config.CrawlerConfig{
+		UserAgent:   "TsumaziroBot/1.0",
+		MaxDepth:    3,
+		MaxPages:    10,
+		Timeout:     5 * time.Second,
+		Concurrency: 2,
+	})
+	result, err := crawler.Crawl(context.Background(), site.URL+"/help/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pages []string
+	for _, page := range result.Pages {
+		pages = append(pages, page.URL)
+	}
+	sort.Strings(pages)
+	want := []string{site.URL + "/help/", site.URL + "/help/moved"}
+	if fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	skipped := map[string]string{}
+	for _, s := range result.Skipped {
+		skipped[s.URL] = s.Reason
+	}
+	for _, path := range []string{"/help/to-private", "/help/to-other", "/help/to-outside"} {
+		if got := skipped[site.URL+path]; got != SkipRedirect {
+			t.Errorf("skip reason of %s = %q, want %q", path, got, SkipRedirect)
+		}
+	}
+}
+
+func TestCrawlResolvesLinksAfterRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/help/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><a href="old/">old</a></body></html>`)
+	})
+	mux.HandleFunc("/help/old/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/help/new/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/help/new/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><a href="child">child</a></body></html>`)
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	crawler := NewCrawler(&config.CrawlerConfig{
+		UserAgent:   "TsumaziroBot/1.0",
+		MaxDepth:    1,
+		MaxPages:    10,
+		Timeout:     5 * time.Second,
+		Concurrency: 1,
+	})
+	result, err := crawler.Crawl(context.Background(), site.URL+"/help/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moved *CrawledPage
+	for _, page := range result.Pages {
+		if page.URL == site.URL+"/help/old/" {
+			moved = page
+		}
+	}
+	if moved == nil {
+		t.Fatalf("pages = %v, want %s among them", result.Pages, site.URL+"/help/old/")
+	}
+	if want := site.URL + "/help/new/"; moved.Canonical != want {
+		t.Errorf("Canonical = %q, want %q", moved.Canonical, want)
+	}
+	if want := []string{site.URL + "/help/new/child"}; fmt.Sprint(moved.Links) != fmt.Sprint(want) {
+		t.Errorf("Links = %v, want %v", moved.Links, want)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	scope := mustParseURL(t, "https://example.com/help/")
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"https://example.com/help/", true},
+		{"https://example.com/help/a/b", true},
+		{"http://EXAMPLE.com/help/a", true},
+		{"https://example.com/blog/", false},
+		{"https://example.com/manuals/guide.pdf", true},
+		{"https://www.example.com/help/", false},
+		{"https://example.com.evil.test/help/", false},
+		{"ftp://example.com/help/", false},
+	}
+	for _, tt := range tests {
+		if got := inScope(scope, mustParseURL(t, tt.in)); got != tt.want {
+			t.Errorf("inScope(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,97 @@
+package batch
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	ContentTypeHTML = "html"
+	ContentTypePDF  = "pdf"
+	ContentTypeText = "text"
+)
+
+var documentExtensions = map[string]string{
+	".pdf": ContentTypePDF,
+	".txt": ContentTypeText,
+}
+
+// contentKind classifies a response by its Content-Type header, falling back
+// to the URL extension and content sniffing for generic types. It returns an
+// empty string for content the crawler cannot use.
+func contentKind(contentType, rawURL string, sniff []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return ContentTypeHTML
+	case "application/pdf", "application/x-pdf":
+		return ContentTypePDF
+	case "text/plain":
+		return ContentTypeText
+	case "", "application/octet-stream", "binary/octet-stream", "application/force-download":
+	default:
+		return ""
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		if kind, ok := documentExtensions[strings.ToLower(path.Ext(u.Path))]; ok {
+			return kind
+		}
+	}
+	if sniff != nil {
+		return contentKind(http.DetectContentType(sniff), "", nil)
+	}
+	return ""
+}
+
+// isDocumentURL reports whether u looks like a linked PDF or text document.
+func isDocumentURL(u *url.URL) bool {
+	_, ok := documentExtensions[strings.ToLower(path.Ext(u.Path))]
+	return ok
+}
+
+// documentTitle uses the file name of a document URL as its title.
+func documentTitle(u *url.URL) string {
+	name := path.Base(u.Path)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return name
+}
+
+// decodeText converts a text/plain body to UTF-8 using the declared or detected charset.
+func decodeText(body []byte, contentType string) string {
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return strings.ToValidUTF8(string(body), "")
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return strings.ToValidUTF8(string(body), "")
+	}
+	return strings.ToValidUTF8(string(decoded), "")
+}
+
+// extractDocument builds the knowledge document for a fetched PDF or text file.
+// The streams of a PDF may inflate to at most maxDecoded bytes.
+func extractDocument(kind string, body []byte, contentType string, u *url.URL, maxDecoded int64) (*Document, error) {
+	doc := &Document{URL: u.String(), Title: documentTitle(u)}
+	switch kind {
+	case ContentTypePDF:
+		text, err := ExtractPDFText(body, maxDecoded)
+		if err != nil {
+			return nil, err
+		}
+		doc.Text = text
+	case ContentTypeText:
+		doc.Text = strings.TrimSpace(decodeText(body, contentType))
+	}
+	return doc, nil
+}
@@ -0,0 +1,464 @@
+package batch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document is the readable content of a crawled page.
+type Document struct {
+	URL   string
+	Title string
+	Text  string
+}
+
+// Markdown renders the document with its title and source URL as a header.
+func (d *Document) Markdown() string {
+	var b strings.Builder
+	if d.Title != "" {
+		b.WriteString("# " + d.Title + "\n\n")
+	}
+	b.WriteString("URL: " + d.URL + "\n\n")
+	b.WriteString(d.Text)
+	b.WriteString("\n")
+	return b.String()
+}
+
+var boilerplateAtoms = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Template: true,
+}
+
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+}
+
+var boilerplatePattern = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|menu|footer|header|sidebar|breadcrumbs?|cookie|banner|share|social|advert|ads?)([\s_-]|$)`)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// ExtractDocument strips navigation, scripts and other boilerplate from a parsed
+// page and renders the main content as Markdown, keeping headings, lists and tables.
+func ExtractDocument(node *html.Node, pageURL string) *Document {
+	doc := &Document{URL: pageURL, Title: pageTitle(node)}
+
+	root := mainContent(node)
+	if root == nil {
+		return doc
+	}
+	var b strings.Builder
+	renderBlock(&b, root, 0)
+	doc.Text = strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n"))
+	if doc.Title == "" {
+		if h1 := findFirst(root, atom.H1); h1 != nil {
+			doc.Title = collapseSpace(textContent(h1))
+		}
+	}
+	return doc
+}
+
+func pageTitle(node *html.Node) string {
+	if title := findFirst(node, atom.Title); title != nil {
+		return collapseSpace(textContent(title))
+	}
+	return ""
+}
+
+func findFirst(node *html.Node, a atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == a {
+		return node
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func isBoilerplate(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return node.Type == html.CommentNode
+	}
+	if boilerplateAtoms[node.DataAtom] {
+		return true
+	}
+	if boilerplateRoles[strings.ToLower(attr(node, "role"))] {
+		return true
+	}
+	if hasAttr(node, "hidden") || strings.EqualFold(attr(node, "aria-hidden"), "true") {
+		return true
+	}
+	return boilerplatePattern.MatchString(attr(node, "class")) || boilerplatePattern.MatchString(attr(node, "id"))
+}
+
+// hasAttr reports whether node carries the attribute key, which for boolean
+// attributes such as hidden is usually written without a value.
+func hasAttr(node *html.Node, key string) bool {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// mainContent prefers <main>, role="main" and <article>, and otherwise picks the
+// block with the most non-link text.
+func mainContent(node *html.Node) *html.Node {
+	var explicit *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if explicit != nil || isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Main || strings.EqualFold(attr(n, "role"), "main")) {
+			explicit = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	if explicit != nil {
+		return explicit
+	}
+	if article := findFirst(node, atom.Article); article != nil && !isBoilerplate(article) {
+		return article
+	}
+
+	body := findFirst(node, atom.Body)
+	if body == nil {
+		return node
+	}
+	best, bestScore := body, 0
+	var score func(n *html.Node)
+	score = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || isBoilerplate(c) {
+				continue
+			}
+			if c.DataAtom == atom.Div || c.DataAtom == atom.Section {
+				text := len(collapseSpace(visibleText(c)))
+				links := len(collapseSpace(linkText(c)))
+				if s := text - 2*links; s > bestScore {
+					best, bestScore = c, s
+				}
+			}
+			score(c)
+		}
+	}
+	score(body)
+	// Prefer the whole body when the best block holds only a small part of the page.
+	if total := len(collapseSpace(visibleText(body))); bestScore*2 < total {
+		return body
+	}
+	return best
+}
+
+func textContent(node *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+func visibleText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+func linkText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			b.WriteString(textContent(n))
+			b.WriteString(" ")
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// inlineText renders phrasing content on a single line.
+func inlineText(node *html.Node) string {
+	var b strings.Builder
+	writeInline(&b, node)
+	return collapseSpace(b.String())
+}
+
+func writeInline(b *strings.Builder, n *html.Node) {
+	if isBoilerplate(n) {
+		return
+	}
+	switch {
+	case n.Type == html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+		b.WriteString(" ")
+		return
+	case n.Type == html.ElementNode && n.DataAtom == atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			b.WriteString(alt)
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeInline(b, c)
+	}
+}
+
+func renderBlock(b *strings.Builder, node *html.Node, listDepth int) {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		renderNode(b, c, listDepth)
+	}
+}
+
+// renderNodes renders a run of sibling nodes, joining adjacent text and inline
+// elements into one paragraph.
+func renderNodes(b *strings.Builder, nodes []*html.Node) {
+	var inline strings.Builder
+	flush := func() {
+		if text := collapseSpace(inline.String()); text != "" {
+			b.WriteString(text + "\n\n")
+		}
+		inline.Reset()
+	}
+	for _, n := range nodes {
+		if n.Type == html.TextNode || (n.Type == html.ElementNode && inlineAtoms[n.DataAtom]) {
+			writeInline(&inline, n)
+			continue
+		}
+		flush()
+		renderNode(b, n, 0)
+	}
+	flush()
+}
+
+// renderNode renders a single node and its children as Markdown.
+func renderNode(b *strings.Builder, c *html.Node, listDepth int) {
+	if isBoilerplate(c) {
+		return
+	}
+	if c.Type == html.TextNode {
+		if text := collapseSpace(c.Data); text != "" {
+			b.WriteString(text + "\n\n")
+		}
+		return
+	}
+	if c.Type != html.ElementNode {
+		return
+	}
+
+	switch c.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(c.Data[1] - '0')
+		if text := inlineText(c); text != "" {
+			b.WriteString(strings.Repeat("#", level) + " " + text + "\n\n")
+		}
+	case atom.P, atom.Summary, atom.Figcaption, atom.Caption:
+		if text := inlineText(c); text != "" {
+			b.WriteString(text + "\n\n")
+		}
+	case atom.Ul, atom.Ol:
+		renderList(b, c, listDepth)
+		if listDepth == 0 {
+			b.WriteString("\n")
+		}
+	case atom.Dl:
+		for item := c.FirstChild; item != nil; item = item.NextSibling {
+			if item.Type != html.ElementNode {
+				continue
+			}
+			text := inlineText(item)
+			if text == "" {
+				continue
+			}
+			if item.DataAtom == atom.Dt {
+				b.WriteString("**" + text + "**\n")
+			} else {
+				b.WriteString(": " + text + "\n")
+			}
+		}
+		b.WriteString("\n")
+	case atom.Table:
+		renderTable(b, c)
+	case atom.Pre:
+		b.WriteString("```\n" + strings.Trim(textContent(c), "\n") + "\n```\n\n")
+	case atom.Blockquote:
+		var inner strings.Builder
+		renderBlock(&inner, c, listDepth)
+		for _, line := range strings.Split(strings.TrimSpace(inner.String()), "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+		b.WriteString("\n")
+	case atom.Hr:
+		b.WriteString("---\n\n")
+	default:
+		if hasBlockChild(c) {
+			renderBlock(b, c, listDepth)
+		} else if text := inlineText(c); text != "" {
+			b.WriteString(text + "\n\n")
+		}
+	}
+}
+
+var inlineAtoms = map[atom.Atom]bool{
+	atom.A:      true,
+	atom.Abbr:   true,
+	atom.B:      true,
+	atom.Br:     true,
+	atom.Code:   true,
+	atom.Em:     true,
+	atom.I:      true,
+	atom.Img:    true,
+	atom.Kbd:    true,
+	atom.Label:  true,
+	atom.Mark:   true,
+	atom.Small:  true,
+	atom.Span:   true,
+	atom.Strong: true,
+	atom.Sub:    true,
+	atom.Sup:    true,
+	atom.Time:   true,
+	atom.U:      true,
+}
+
+func hasBlockChild(node *html.Node) bool {
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && !inlineAtoms[c.DataAtom] && !isBoilerplate(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderList(b *strings.Builder, list *html.Node, depth int) {
+	n := 0
+	for item := list.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li {
+			continue
+		}
+		n++
+		marker := "- "
+		if list.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", n)
+		}
+
+		var text strings.Builder
+		var nested []*html.Node
+		for c := item.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) {
+				nested = append(nested, c)
+				continue
+			}
+			if c.Type == html.TextNode {
+				text.WriteString(c.Data)
+			} else {
+				text.WriteString(" " + inlineText(c) + " ")
+			}
+		}
+		b.WriteString(strings.Repeat("  ", depth) + marker + collapseSpace(text.String()) + "\n")
+		for _, child := range nested {
+			renderList(b, child, depth+1)
+		}
+	}
+}
+
+func renderTable(b *strings.Builder, table *html.Node) {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Tr {
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						row = append(row, strings.ReplaceAll(inlineText(cell), "|", "\\|"))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(table)
+	if len(rows) == 0 {
+		return
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	b.WriteString("\n")
+}
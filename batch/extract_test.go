@@ -0,0 +1,36 @@
+package batch
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtractDocumentSkipsHidden(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"bare hidden", `<div hidden>SECRET</div>`},
+		{"hidden with value", `<div hidden="hidden">SECRET</div>`},
+		{"aria-hidden", `<div aria-hidden="true">SECRET</div>`},
+		{"navigation", `<nav>SECRET</nav>`},
+		{"boilerplate class", `<div class="site-footer">SECRET</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := html.Parse(strings.NewReader(`<html><head><title>Help</title></head><body><main><p>Visible answer.</p>` + tt.body + `</main></body></html>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := ExtractDocument(node, "https://example.com/help")
+			if strings.Contains(doc.Text, "SECRET") {
+				t.Errorf("Text = %q, want hidden content left out", doc.Text)
+			}
+			if !strings.Contains(doc.Text, "Visible answer.") {
+				t.Errorf("Text = %q, want the visible content", doc.Text)
+			}
+		})
+	}
+}
@@ -8,9 +8,9 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
 	"github.com/yamato0211/tsumaziro-faq-server/db/model"
 	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
 )
@@ -28,45 +28,128 @@ type Page struct {
 }
 
 type FAQ struct {
-	Question  string `json:"question"`
-	PageTitle string `json:"pageTitle"`
+	Question   string   `json:"question"`
+	PageTitle  string   `json:"pageTitle"`
+	Categories []string `json:"categories,omitempty"`
 }
 
 const QuestionTextPrefix = "? "
 
-func BatchGenerateFAQ(db *db.DB, ctx context.Context) error {
-	var accounts []*model.Account
-	if err := db.DB.NewSelect().Model((*model.Account)(nil)).Scan(ctx, &accounts); err != nil {
-		errors.WithStack(err)
+// GenerateFAQ adds the questions of an account's Scrapbox project as draft FAQs
+// and returns the number of pages read. Existing entries, including edited,
+// approved and hidden ones, are left as they are.
+func GenerateFAQ(db *db.DB, ctx context.Context, account *model.Account) (int, error) {
+	// Website-only accounts get their FAQs from crawled pages instead.
+	if account.ProjectID == "" {
+		return 0, nil
+	}
+	titles, err := getPageTitles(account.ProjectID)
+	if err != nil {
+		return 0, errors.WithStack(err)
 	}
-	fmt.Println("accounts")
-	fmt.Println(accounts)
 
-	for _, account := range accounts {
-		titles, err := getPageTitles(account.ProjectID)
+	var faqs []*model.FAQ
+	var links []*model.PageLink
+	seen := map[string]bool{}
+	for _, title := range titles {
+		pageFaqs, pageLinks, err := convertPageToFAQs(account.ProjectID, title)
 		if err != nil {
-			return errors.WithStack(err)
+			return 0, errors.WithStack(err)
+		}
+		for _, target := range pageLinks {
+			links = append(links, &model.PageLink{AccountID: account.ID, Source: title, Target: target})
+		}
+		for _, faq := range pageFaqs {
+			fingerprint := model.FAQFingerprint(faq.Question)
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			faqs = append(faqs, &model.FAQ{
+				AccountID:   account.ID,
+				Fingerprint: fingerprint,
+				Question:    faq.Question,
+				Status:      model.FAQStatusDraft,
+				Source:      model.FAQSourceScrapbox,
+				PageTitle:   faq.PageTitle,
+				Tags:        faq.Categories,
+			})
+		}
+	}
+	if len(faqs) > 0 {
+		// Tags are only filled in for entries that never had any, so
+		// categories chosen during review are kept.
+		if _, err := db.DB.NewInsert().Model(&faqs).
+			On("DUPLICATE KEY UPDATE").
+			Set("tags = COALESCE(tags, VALUES(tags))").
+			Exec(ctx); err != nil {
+			return 0, errors.WithStack(err)
 		}
+	}
+	if err := replacePageLinks(db, ctx, account.ID, links); err != nil {
+		return 0, err
+	}
+	return len(titles), nil
+}
+
+const pageLinkBatchSize = 500
 
-		var faqs []FAQ
-		for _, title := range titles {
-			pageFaqs, err := convertPageToFAQs(account.ProjectID, title)
-			if err != nil {
-				return errors.WithStack(err)
+// replacePageLinks swaps the stored link graph of an account for links.
+func replacePageLinks(db *db.DB, ctx context.Context, accountID string, links []*model.PageLink) error {
+	err := db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.PageLink)(nil)).Where("account_id = ?", accountID).Exec(ctx); err != nil {
+			return err
+		}
+		for start := 0; start < len(links); start += pageLinkBatchSize {
+			chunk := links[start:min(start+pageLinkBatchSize, len(links))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return err
 			}
-			faqs = append(faqs, pageFaqs...)
 		}
-		marshaled, err := json.Marshal(faqs)
-		if err != nil {
-			return errors.WithStack(err)
+		return nil
+	})
+	return errors.WithStack(err)
+}
+
+// StoreFAQCandidates saves the questions found by a crawl as draft FAQs and
+// returns how many were new. Questions the account already has are left
+// untouched so review decisions are kept across crawls.
+func StoreFAQCandidates(db *db.DB, ctx context.Context, accountID string, manifest *Manifest) (int, error) {
+	var faqs []*model.FAQ
+	seen := map[string]bool{}
+	for _, entry := range manifest.Pages {
+		if entry.Status != PageStatusIndexed {
+			continue
 		}
-		account.Faqs = marshaled
-		account.UpdatedAt = time.Now()
-		if _, err = db.DB.NewUpdate().Model(account).WherePK().Exec(ctx); err != nil {
-			return errors.WithStack(err)
+		for _, candidate := range entry.FAQs {
+			fingerprint := model.FAQFingerprint(candidate.Question)
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			faqs = append(faqs, &model.FAQ{
+				AccountID:   accountID,
+				Fingerprint: fingerprint,
+				Question:    candidate.Question,
+				Answer:      candidate.Answer,
+				Status:      model.FAQStatusDraft,
+				Source:      model.FAQSourceCrawl,
+				SourceURL:   entry.URL,
+			})
 		}
 	}
-	return nil
+	if len(faqs) == 0 {
+		return 0, nil
+	}
+	res, err := db.DB.NewInsert().Model(&faqs).Ignore().Exec(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	added, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return int(added), nil
 }
 
 func getPageTitles(projectName string) ([]string, error) {
@@ -92,33 +175,96 @@ func getPageTitles(projectName string) ([]string, error) {
 	return titles, nil
 }
 
-func convertPageToFAQs(projectName, pageTitle string) ([]FAQ, error) {
+// convertPageToFAQs returns the questions of a Scrapbox page together with the
+// pages it links to.
+func convertPageToFAQs(projectName, pageTitle string) ([]FAQ, []string, error) {
 	res, err := http.Get(fmt.Sprintf("https://scrapbox.io/api/pages/%s/%s", projectName, pageTitle))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var page Page
 	if err := json.Unmarshal(body, &page); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var faqs []FAQ
+	var lines []string
 	for _, line := range page.Lines[1:] {
-		if strings.HasPrefix(line.Text, QuestionTextPrefix) {
-			questionText := strings.TrimPrefix(line.Text, QuestionTextPrefix)
+		lines = append(lines, line.Text)
+	}
+	categories := pageCategories(lines)
+
+	var faqs []FAQ
+	for _, line := range lines {
+		if strings.HasPrefix(line, QuestionTextPrefix) {
+			questionText := strings.TrimPrefix(line, QuestionTextPrefix)
 			questions := convertTextToQuestions(questionText)
 			for _, question := range questions {
-				faqs = append(faqs, FAQ{Question: question, PageTitle: pageTitle})
+				faqs = append(faqs, FAQ{Question: question, PageTitle: pageTitle, Categories: categories})
+			}
+		}
+	}
+	// Hashtags are links to the page of the same name, so the categories are
+	// also the outgoing links of the page.
+	return faqs, categories, nil
+}
+
+var (
+	hashtagPattern     = regexp.MustCompile(`(?:^|\s)#([^\s\[\]#]+)`)
+	bracketPattern     = regexp.MustCompile(`\[([^\[\]]+)\]`)
+	inlineCodePattern  = regexp.MustCompile("`[^`]*`")
+	bracketDecorations = "*/-_!$\""
+)
+
+// pageCategories collects the #hashtags and [bracket links] of a Scrapbox page.
+// Code blocks, inline code, text decorations, icons and external links are
+// not categories and are skipped.
+func pageCategories(lines []string) []string {
+	var categories []string
+	codeIndent := -1
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t　")
+		indent := len(line) - len(trimmed)
+		if codeIndent >= 0 {
+			if indent > codeIndent {
+				continue
+			}
+			codeIndent = -1
+		}
+		if strings.HasPrefix(trimmed, "code:") {
+			codeIndent = indent
+			continue
+		}
+
+		trimmed = inlineCodePattern.ReplaceAllString(trimmed, "")
+		for _, m := range hashtagPattern.FindAllStringSubmatch(trimmed, -1) {
+			categories = append(categories, m[1])
+		}
+		for _, m := range bracketPattern.FindAllStringSubmatch(trimmed, -1) {
+			if link := m[1]; isPageLink(link) {
+				categories = append(categories, link)
 			}
 		}
 	}
-	return faqs, nil
+	if len(categories) == 0 {
+		return nil
+	}
+	return model.NormalizeTags(categories)
+}
+
+func isPageLink(link string) bool {
+	if link == "" || strings.ContainsAny(link[:1], bracketDecorations) {
+		return false
+	}
+	if strings.Contains(link, "://") || strings.HasSuffix(link, ".icon") || strings.Contains(link, ".icon*") {
+		return false
+	}
+	return true
 }
 
 func convertTextToQuestions(text string) []string {
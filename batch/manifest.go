@@ -0,0 +1,87 @@
+package batch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/storage"
+)
+
+const (
+	ManifestFileName = "manifest.json"
+
+	PageStatusIndexed = "indexed"
+
+	ChangeAdded     = "added"
+	ChangeChanged   = "changed"
+	ChangeUnchanged = "unchanged"
+)
+
+// ManifestEntry records the outcome of one URL in a crawl run.
+// Status is PageStatusIndexed for stored pages and the skip reason otherwise.
+type ManifestEntry struct {
+	URL          string          `json:"url"`
+	RequestURL   string          `json:"request_url,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	ContentType  string          `json:"content_type,omitempty"`
+	ObjectKey    string          `json:"object_key,omitempty"`
+	ContentHash  string          `json:"content_hash,omitempty"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Links        []string        `json:"links,omitempty"`
+	FAQs         []*FAQCandidate `json:"faqs,omitempty"`
+	FetchedAt    *time.Time      `json:"fetched_at,omitempty"`
+	Status       string          `json:"status"`
+	Change       string          `json:"change,omitempty"`
+}
+
+// ManifestChanges lists the page URLs that differ from the previous crawl.
+type ManifestChanges struct {
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// Manifest lists every page of one crawl version.
+type Manifest struct {
+	SeedURL   string           `json:"seed_url"`
+	Version   string           `json:"version"`
+	Prefix    string           `json:"prefix"`
+	CreatedAt time.Time        `json:"created_at"`
+	Changes   ManifestChanges  `json:"changes"`
+	Pages     []*ManifestEntry `json:"pages"`
+}
+
+// loadManifest returns the manifest stored at key, or nil when there is none.
+func loadManifest(ctx context.Context, store storage.BlobStore, key string) (*Manifest, error) {
+	r, err := store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// pageObjectName derives a stable object name from the page URL so that the
+// same page keeps the same name across crawl versions.
+func pageObjectName(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return hex.EncodeToString(sum[:16]) + ".md"
+}
@@ -0,0 +1,836 @@
+package batch
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// This file implements just enough of PDF to pull the text out of typical
+// help-site manuals: indirect objects, object streams, FlateDecode, page
+// trees, and ToUnicode CMaps for CID fonts. Layout is approximated with
+// newlines between text lines.
+
+type pdfName string
+
+type pdfKeyword string
+
+type pdfRef struct {
+	num, gen int
+}
+
+type pdfDict map[string]any
+
+type pdfObject struct {
+	value  any
+	stream []byte
+}
+
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	cmaps   map[int]*toUnicode
+	// budget is how many more bytes streams may inflate to; err is set once
+	// they tried to go past it.
+	budget int64
+	err    error
+}
+
+// defaultMaxDecodedStream caps inflated streams when no limit is given.
+const defaultMaxDecodedStream = 64 << 20
+
+var errPDFTooLarge = errors.New("pdf: decoded streams exceed the size limit")
+
+var objHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// ExtractPDFText returns the text of every page of a PDF file, in page order.
+// Files come from arbitrary sites, so a parser bug on malformed input is
+// reported as an error rather than taking the process down, and all streams
+// together may inflate to at most maxDecoded bytes. A non-positive maxDecoded
+// uses a default limit.
+func ExtractPDFText(data []byte, maxDecoded int64) (text string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			text, err = "", fmt.Errorf("pdf: malformed document: %v", p)
+		}
+	}()
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return "", errors.New("pdf: missing %PDF header")
+	}
+	if maxDecoded <= 0 {
+		maxDecoded = defaultMaxDecodedStream
+	}
+	doc := &pdfDocument{objects: map[int]*pdfObject{}, cmaps: map[int]*toUnicode{}, budget: maxDecoded}
+	doc.parseObjects(data)
+	for _, obj := range doc.objects {
+		if d, ok := obj.value.(pdfDict); ok && d["Encrypt"] != nil {
+			return "", errors.New("pdf: encrypted documents are not supported")
+		}
+	}
+	doc.expandObjectStreams()
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		if doc.err != nil {
+			return "", doc.err
+		}
+		return "", errors.New("pdf: no pages found")
+	}
+	var b strings.Builder
+	for _, page := range pages {
+		fonts := doc.pageFonts(page)
+		for _, content := range doc.pageContents(page) {
+			b.WriteString(extractContentText(content, fonts))
+			b.WriteString("\n")
+		}
+	}
+	if doc.err != nil {
+		return "", doc.err
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n")), nil
+}
+
+func (doc *pdfDocument) parseObjects(data []byte) {
+	for _, m := range objHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		lx := &pdfLexer{data: data, pos: m[1]}
+		value, err := lx.value()
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{value: value}
+		if tok, err := lx.next(); err == nil && tok == pdfKeyword("stream") {
+			start := lx.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			end := bytes.Index(data[start:], []byte("endstream"))
+			if end < 0 {
+				continue
+			}
+			obj.stream = bytes.TrimRight(data[start:start+end], "\r\n")
+		}
+		// Later definitions replace earlier ones, as in incremental updates.
+		doc.objects[num] = obj
+	}
+}
+
+func (doc *pdfDocument) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, ok := doc.objects[ref.num]
+		if !ok {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (doc *pdfDocument) dict(v any) pdfDict {
+	d, _ := doc.resolve(v).(pdfDict)
+	return d
+}
+
+// streamData returns the decoded stream of the object v refers to.
+func (doc *pdfDocument) streamData(v any) []byte {
+	ref, ok := v.(pdfRef)
+	if !ok {
+		return nil
+	}
+	obj, ok := doc.objects[ref.num]
+	if !ok || obj.stream == nil {
+		return nil
+	}
+	if doc.err != nil {
+		return nil
+	}
+	d, _ := obj.value.(pdfDict)
+	data, err := decodeStream(doc.resolve(d["Filter"]), obj.stream, doc.budget)
+	if err != nil {
+		if errors.Is(err, errPDFTooLarge) {
+			doc.err = err
+		}
+		return nil
+	}
+	doc.budget -= int64(len(data))
+	return data
+}
+
+// decodeStream applies the stream filters to raw. Inflating past limit bytes
+// fails with errPDFTooLarge.
+func decodeStream(filter any, raw []byte, limit int64) ([]byte, error) {
+	var filters []any
+	switch f := filter.(type) {
+	case nil:
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	data := raw
+	for _, f := range filters {
+		switch f {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Truncated streams are common; keep whatever was inflated.
+			decoded, err := io.ReadAll(io.LimitReader(r, limit+1))
+			if int64(len(decoded)) > limit {
+				return nil, errPDFTooLarge
+			}
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("pdf: unsupported filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// expandObjectStreams loads objects packed into /Type /ObjStm streams.
+func (doc *pdfDocument) expandObjectStreams() {
+	var streams []int
+	for num, obj := range doc.objects {
+		if d, ok := obj.value.(pdfDict); ok && d["Type"] == pdfName("ObjStm") {
+			streams = append(streams, num)
+		}
+	}
+	sort.Ints(streams)
+	for _, num := range streams {
+		obj := doc.objects[num]
+		d := obj.value.(pdfDict)
+		data := doc.streamData(pdfRef{num: num})
+		n, _ := doc.resolve(d["N"]).(float64)
+		first, _ := doc.resolve(d["First"]).(float64)
+		if data == nil || first < 0 || int(first) > len(data) {
+			continue
+		}
+
+		header := &pdfLexer{data: data[:int(first)]}
+		for i := 0; i < int(n); i++ {
+			numTok, err1 := header.value()
+			offTok, err2 := header.value()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			objNum, _ := numTok.(float64)
+			offset, _ := offTok.(float64)
+			if _, exists := doc.objects[int(objNum)]; exists {
+				continue
+			}
+			if offset < 0 || int(first)+int(offset) >= len(data) {
+				continue
+			}
+			lx := &pdfLexer{data: data, pos: int(first) + int(offset)}
+			if value, err := lx.value(); err == nil {
+				doc.objects[int(objNum)] = &pdfObject{value: value}
+			}
+		}
+	}
+}
+
+// pages returns the page dictionaries in document order.
+func (doc *pdfDocument) pages() []pdfDict {
+	var root pdfDict
+	for _, obj := range doc.objects {
+		if d, ok := obj.value.(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+			root = d
+			break
+		}
+	}
+	var pages []pdfDict
+	if root != nil {
+		seen := map[int]bool{}
+		var walk func(v any)
+		walk = func(v any) {
+			if ref, ok := v.(pdfRef); ok {
+				if seen[ref.num] {
+					return
+				}
+				seen[ref.num] = true
+			}
+			node := doc.dict(v)
+			if node == nil {
+				return
+			}
+			switch node["Type"] {
+			case pdfName("Pages"):
+				kids, _ := doc.resolve(node["Kids"]).([]any)
+				for _, kid := range kids {
+					walk(kid)
+				}
+			case pdfName("Page"):
+				pages = append(pages, node)
+			}
+		}
+		walk(root["Pages"])
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	var nums []int
+	for num, obj := range doc.objects {
+		if d, ok := obj.value.(pdfDict); ok && d["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		pages = append(pages, doc.objects[num].value.(pdfDict))
+	}
+	return pages
+}
+
+func (doc *pdfDocument) pageContents(page pdfDict) [][]byte {
+	var refs []any
+	switch c := page["Contents"].(type) {
+	case pdfRef:
+		if arr, ok := doc.resolve(c).([]any); ok {
+			refs = arr
+		} else {
+			refs = []any{c}
+		}
+	case []any:
+		refs = c
+	}
+	// Content arrays are a single stream split at arbitrary token boundaries.
+	var joined []byte
+	for _, ref := range refs {
+		joined = append(joined, doc.streamData(ref)...)
+		joined = append(joined, '\n')
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return [][]byte{joined}
+}
+
+// pageFonts maps font resource names to their ToUnicode CMaps. Resources are
+// inherited from parent page tree nodes.
+func (doc *pdfDocument) pageFonts(page pdfDict) map[string]*toUnicode {
+	fonts := map[string]*toUnicode{}
+	node := page
+	for depth := 0; node != nil && depth < 32; depth++ {
+		resources := doc.dict(node["Resources"])
+		if resources != nil {
+			for name, fontRef := range doc.dict(resources["Font"]) {
+				if _, ok := fonts[name]; ok {
+					continue
+				}
+				fonts[name] = doc.fontCMap(fontRef)
+			}
+			break
+		}
+		node = doc.dict(node["Parent"])
+	}
+	return fonts
+}
+
+func (doc *pdfDocument) fontCMap(fontRef any) *toUnicode {
+	font := doc.dict(fontRef)
+	if font == nil {
+		return nil
+	}
+	ref, ok := font["ToUnicode"].(pdfRef)
+	if !ok {
+		return nil
+	}
+	if cmap, ok := doc.cmaps[ref.num]; ok {
+		return cmap
+	}
+	cmap := parseToUnicode(doc.streamData(ref))
+	doc.cmaps[ref.num] = cmap
+	return cmap
+}
+
+// extractContentText interprets the text operators of a page content stream.
+func extractContentText(content []byte, fonts map[string]*toUnicode) string {
+	var b strings.Builder
+	var operands []any
+	var cmap *toUnicode
+
+	newline := func() {
+		if s := b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	show := func(v any) {
+		if s, ok := v.([]byte); ok {
+			b.WriteString(cmap.decode(s))
+		}
+	}
+
+	lx := &pdfLexer{data: content}
+	for {
+		tok, err := lx.value()
+		if err != nil {
+			break
+		}
+		op, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					cmap = fonts[string(name)]
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].([]any)
+				for _, item := range arr {
+					// Large negative kerning is how many generators encode a space.
+					if n, ok := item.(float64); ok && n < -200 {
+						b.WriteString(" ")
+						continue
+					}
+					show(item)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					newline()
+				}
+			}
+		case "T*", "ET":
+			newline()
+		case "BI":
+			lx.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	return b.String()
+}
+
+// toUnicode is a parsed ToUnicode CMap.
+type toUnicode struct {
+	codeLen int
+	chars   map[uint32]string
+}
+
+var (
+	cmapCodespace = regexp.MustCompile(`begincodespacerange\s*<([0-9A-Fa-f]+)>`)
+	cmapBfchar    = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	cmapBfrange   = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	cmapHex       = regexp.MustCompile(`<([0-9A-Fa-f]*)>|\[|\]`)
+)
+
+func parseToUnicode(data []byte) *toUnicode {
+	if data == nil {
+		return nil
+	}
+	cmap := &toUnicode{codeLen: 1, chars: map[uint32]string{}}
+	if m := cmapCodespace.FindSubmatch(data); m != nil {
+		cmap.codeLen = (len(m[1]) + 1) / 2
+	}
+
+	for _, section := range cmapBfchar.FindAllSubmatch(data, -1) {
+		tokens := hexTokens(section[1])
+		for i := 0; i+1 < len(tokens); i += 2 {
+			cmap.chars[hexCode(tokens[i])] = utf16Hex(tokens[i+1])
+		}
+	}
+	for _, section := range cmapBfrange.FindAllSubmatch(data, -1) {
+		tokens := hexTokens(section[1])
+		for i := 0; i+2 < len(tokens); {
+			lo, hi := hexCode(tokens[i]), hexCode(tokens[i+1])
+			if hi < lo || hi-lo > 0xffff {
+				break
+			}
+			if tokens[i+2] == "[" {
+				j := i + 3
+				for code := lo; j < len(tokens) && tokens[j] != "]"; code, j = code+1, j+1 {
+					cmap.chars[code] = utf16Hex(tokens[j])
+				}
+				i = j + 1
+				continue
+			}
+			dst := []rune(utf16Hex(tokens[i+2]))
+			for code := lo; code <= hi && len(dst) > 0; code++ {
+				cmap.chars[code] = string(dst)
+				dst[len(dst)-1]++
+			}
+			i += 3
+		}
+	}
+	return cmap
+}
+
+func hexTokens(data []byte) []string {
+	var tokens []string
+	for _, m := range cmapHex.FindAllSubmatch(data, -1) {
+		if m[1] == nil {
+			tokens = append(tokens, string(m[0]))
+		} else {
+			tokens = append(tokens, string(m[1]))
+		}
+	}
+	return tokens
+}
+
+func hexCode(s string) uint32 {
+	n, _ := strconv.ParseUint(s, 16, 32)
+	return uint32(n)
+}
+
+func utf16Hex(s string) string {
+	var units []uint16
+	for i := 0; i+4 <= len(s); i += 4 {
+		n, _ := strconv.ParseUint(s[i:i+4], 16, 16)
+		units = append(units, uint16(n))
+	}
+	if len(units) == 0 && len(s) >= 2 {
+		n, _ := strconv.ParseUint(s, 16, 16)
+		units = append(units, uint16(n))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode maps character codes to text. Without a CMap the bytes are read as
+// Latin-1, which is right for the standard encodings of simple fonts.
+func (c *toUnicode) decode(s []byte) string {
+	if c == nil {
+		var b strings.Builder
+		for _, ch := range s {
+			if ch >= 0x20 || ch == '\t' {
+				b.WriteRune(rune(ch))
+			}
+		}
+		return b.String()
+	}
+	var b strings.Builder
+	for i := 0; i+c.codeLen <= len(s); i += c.codeLen {
+		var code uint32
+		for _, ch := range s[i : i+c.codeLen] {
+			code = code<<8 | uint32(ch)
+		}
+		if text, ok := c.chars[code]; ok {
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// maxPDFNesting bounds how deeply arrays and dictionaries may nest, so that a
+// hostile file cannot exhaust the stack.
+const maxPDFNesting = 64
+
+// pdfLexer reads PDF tokens and values from object data or content streams.
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if isPDFSpace(c) {
+			lx.pos++
+			continue
+		}
+		if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+var (
+	errPDFEOF     = errors.New("pdf: unexpected end of data")
+	errPDFNesting = errors.New("pdf: values nested too deeply")
+)
+
+type pdfDelim string
+
+// next returns the next token: a number, name, string, keyword or delimiter.
+func (lx *pdfLexer) next() (any, error) {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil, errPDFEOF
+	}
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		lx.pos++
+		start := lx.pos
+		for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+			lx.pos++
+		}
+		return pdfName(unescapeName(string(lx.data[start:lx.pos]))), nil
+	case c == '(':
+		return lx.literalString(), nil
+	case c == '<':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<' {
+			lx.pos += 2
+			return pdfDelim("<<"), nil
+		}
+		return lx.hexString(), nil
+	case c == '>':
+		if lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '>' {
+			lx.pos += 2
+			return pdfDelim(">>"), nil
+		}
+		lx.pos++
+		return pdfDelim(">"), nil
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		lx.pos++
+		return pdfDelim(string(c)), nil
+	}
+
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos == start {
+		lx.pos++
+		return pdfKeyword(string(c)), nil
+	}
+	word := string(lx.data[start:lx.pos])
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// value reads a complete value, assembling arrays, dictionaries and "n g R" references.
+func (lx *pdfLexer) value() (any, error) {
+	tok, err := lx.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case pdfDelim:
+		if t == "[" || t == "<<" {
+			if lx.depth >= maxPDFNesting {
+				return nil, errPDFNesting
+			}
+			lx.depth++
+			defer func() { lx.depth-- }()
+		}
+		switch t {
+		case "[":
+			var arr []any
+			for {
+				lx.skipSpace()
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == ']' {
+					lx.pos++
+					return arr, nil
+				}
+				v, err := lx.value()
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+		case "<<":
+			d := pdfDict{}
+			for {
+				lx.skipSpace()
+				if lx.pos >= len(lx.data) {
+					return nil, errPDFEOF
+				}
+				if bytes.HasPrefix(lx.data[lx.pos:], []byte(">>")) {
+					lx.pos += 2
+					return d, nil
+				}
+				key, err := lx.next()
+				if err != nil {
+					return nil, err
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue
+				}
+				v, err := lx.value()
+				if err != nil {
+					return nil, err
+				}
+				d[string(name)] = v
+			}
+		}
+		return t, nil
+	case float64:
+		// Look ahead for an indirect reference.
+		save := lx.pos
+		if gen, err := lx.next(); err == nil {
+			if g, ok := gen.(float64); ok {
+				if r, err := lx.next(); err == nil && r == pdfKeyword("R") {
+					return pdfRef{num: int(t), gen: int(g)}, nil
+				}
+			}
+		}
+		lx.pos = save
+		return t, nil
+	}
+	return tok, nil
+}
+
+func (lx *pdfLexer) literalString() []byte {
+	lx.pos++ // (
+	var out []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return out
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						n = n*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (lx *pdfLexer) hexString() []byte {
+	lx.pos++ // <
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		c := lx.data[lx.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	if lx.pos < len(lx.data) {
+		lx.pos++ // >
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// skipInlineImage jumps over binary inline image data up to the EI operator.
+func (lx *pdfLexer) skipInlineImage() {
+	i := bytes.Index(lx.data[lx.pos:], []byte("ID"))
+	if i < 0 {
+		lx.pos = len(lx.data)
+		return
+	}
+	lx.pos += i + 2
+	for {
+		j := bytes.Index(lx.data[lx.pos:], []byte("EI"))
+		if j < 0 {
+			lx.pos = len(lx.data)
+			return
+		}
+		lx.pos += j + 2
+		if j > 0 && isPDFSpace(lx.data[lx.pos-3]) && (lx.pos >= len(lx.data) || isPDFSpace(lx.data[lx.pos])) {
+			return
+		}
+	}
+}
+
+func unescapeName(s string) string {
+	if !strings.Contains(s, "#") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package batch
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// minimalPDF is a one-page document whose page shows "Hello, FAQ".
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj
+4 0 obj << /Length 36 >>
+stream
+BT /F1 12 Tf (Hello, FAQ) Tj ET
+endstream
+endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestExtractPDFText(t *testing.T) {
+	text, err := ExtractPDFText([]byte(minimalPDF), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Hello, FAQ") {
+		t.Errorf("ExtractPDFText = %q, want it to contain %q", text, "Hello, FAQ")
+	}
+}
+
+// TestExtractPDFTextDecodedLimit checks that a small deflated stream cannot
+// inflate past the limit.
+func TestExtractPDFTextDecodedLimit(t *testing.T) {
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(bytes.Repeat([]byte("BT (A) Tj ET\n"), 1<<20))
+	zw.Close()
+	data := fmt.Sprintf(`%%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj
+4 0 obj << /Length %d /Filter /FlateDecode >>
+stream
+%s
+endstream
+endobj
+`, deflated.Len(), deflated.Bytes())
+
+	tests := []struct {
+		name  string
+		limit int64
+		want  error
+	}{
+		{"over the limit", 1 << 20, errPDFTooLarge},
+		{"under the limit", 32 << 20, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExtractPDFText([]byte(data), tt.limit); !errors.Is(err, tt.want) {
+				t.Errorf("ExtractPDFText = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestExtractPDFTextMalformed feeds documents that used to crash the lexer or
+// the object stream reader. Each must be handled by the parser itself rather
+// than by the recover in ExtractPDFText, and must not hang.
+func TestExtractPDFTextMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no header", "1 0 obj << >> endobj"},
+		{"header only", "%PDF-1.7"},
+		{"unterminated hex string", "%PDF-1.4\n1 0 obj <41"},
+		{"unterminated literal string", "%PDF-1.4\n1 0 obj (abc"},
+		{"unterminated dictionary", "%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R"},
+		{"unterminated array", "%PDF-1.4\n1 0 obj [1 2 3"},
+		{"dictionary key without value", "%PDF-1.4\n1 0 obj << /Type"},
+		{"deep array nesting", "%PDF-1.4\n1 0 obj " + strings.Repeat("[", 100000)},
+		{"deep dictionary nesting", "%PDF-1.4\n1 0 obj " + strings.Repeat("<< /A ", 100000)},
+		{"stream without endstream", "%PDF-1.4\n1 0 obj << /Length 10 >>\nstream\nabc"},
+		{"negative First", "%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First -5 >>\nstream\n2 0 << >>\nendstream\nendobj"},
+		{"First past the end", "%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First 1000 >>\nstream\n2 0\nendstream\nendobj"},
+		{"negative offset", "%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First 6 >>\nstream\n2 -90 << >>\nendstream\nendobj"},
+		{"offset past the end", "%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First 7 >>\nstream\n2 500 << >>\nendstream\nendobj"},
+		{"huge N", "%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1e18 /First 4 >>\nstream\n2 0 << >>\nendstream\nendobj"},
+		{"broken flate stream", "%PDF-1.4\n1 0 obj << /Type /ObjStm /Filter /FlateDecode /N 1 /First 4 >>\nstream\nnot zlib\nendstream\nendobj"},
+		{"self-referencing pages", "%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Type /Pages /Kids [2 0 R] >> endobj"},
+		{"truncated", minimalPDF[:len(minimalPDF)/2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExtractPDFText([]byte(tt.data), 1<<20); err != nil && strings.Contains(err.Error(), "malformed document") {
+				t.Errorf("ExtractPDFText panicked: %v", err)
+			}
+		})
+	}
+}
+
+func FuzzExtractPDFText(f *testing.F) {
+	f.Add([]byte(minimalPDF))
+	f.Add([]byte("%PDF-1.4\n1 0 obj <41"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj << /Type /ObjStm /N 1 /First -5 >>\nstream\n2 0 << >>\nendstream\nendobj"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		text, err := ExtractPDFText(data, 1<<20)
+		if err != nil && strings.Contains(err.Error(), "malformed document") {
+			t.Errorf("ExtractPDFText panicked: %v", err)
+		}
+		if err != nil && text != "" {
+			t.Errorf("ExtractPDFText returned text %q with error %v", text, err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+)
+
+// maxLinkDistance is how many links apart two pages may be and still count as related.
+const maxLinkDistance = 2
+
+// RelatedFAQ is an FAQ ranked against another one. Distance is the number of
+// links between their pages, omitted when the pages are not connected.
+type RelatedFAQ struct {
+	*model.FAQ
+	Score      int      `json:"score"`
+	Distance   *int     `json:"distance,omitempty"`
+	SharedTags []string `json:"shared_tags,omitempty"`
+}
+
+// pageKey matches Scrapbox titles the way Scrapbox does: case-insensitively
+// and treating underscores as spaces.
+func pageKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(title, "_", " ")), " "))
+}
+
+// linkDistances walks the link graph in both directions from start and
+// returns the distance to every page within maxLinkDistance.
+func linkDistances(links []*model.PageLink, start string) map[string]int {
+	neighbours := map[string][]string{}
+	for _, link := range links {
+		source, target := pageKey(link.Source), pageKey(link.Target)
+		neighbours[source] = append(neighbours[source], target)
+		neighbours[target] = append(neighbours[target], source)
+	}
+
+	start = pageKey(start)
+	distances := map[string]int{start: 0}
+	frontier := []string{start}
+	for depth := 1; depth <= maxLinkDistance && len(frontier) > 0; depth++ {
+		var next []string
+		for _, page := range frontier {
+			for _, neighbour := range neighbours[page] {
+				if _, ok := distances[neighbour]; ok {
+					continue
+				}
+				distances[neighbour] = depth
+				next = append(next, neighbour)
+			}
+		}
+		frontier = next
+	}
+	return distances
+}
+
+// RankRelatedFAQs scores candidates against faq. Closer pages score higher,
+// with FAQs from the same page scoring highest, and every shared tag adds a
+// point. Candidates with no connection are dropped.
+func RankRelatedFAQs(faq *model.FAQ, candidates []*model.FAQ, links []*model.PageLink, limit int) []*RelatedFAQ {
+	var distances map[string]int
+	if faq.PageTitle != "" {
+		distances = linkDistances(links, faq.PageTitle)
+	}
+	tags := map[string]bool{}
+	for _, tag := range faq.Tags {
+		tags[strings.ToLower(tag)] = true
+	}
+
+	related := []*RelatedFAQ{}
+	for _, candidate := range candidates {
+		if candidate.ID == faq.ID {
+			continue
+		}
+		r := &RelatedFAQ{FAQ: candidate}
+		if candidate.PageTitle != "" {
+			if d, ok := distances[pageKey(candidate.PageTitle)]; ok {
+				r.Distance = &d
+				r.Score += maxLinkDistance + 1 - d
+			}
+		}
+		for _, tag := range candidate.Tags {
+			if tags[strings.ToLower(tag)] {
+				r.SharedTags = append(r.SharedTags, tag)
+				r.Score++
+			}
+		}
+		if r.Score > 0 {
+			related = append(related, r)
+		}
+	}
+
+	sort.SliceStable(related, func(i, j int) bool {
+		return related[i].Score > related[j].Score
+	})
+	if limit > 0 && len(related) > limit {
+		related = related[:limit]
+	}
+	return related
+}
@@ -0,0 +1,168 @@
+package batch
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// Robots is a parsed robots.txt file.
+type Robots struct {
+	groups   []*robotsGroup
+	Sitemaps []string
+}
+
+func parseRobots(r io.Reader) *Robots {
+	robots := &Robots{}
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				robots.groups = append(robots.groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (key == "disallow" && value == "") {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", path: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			robots.Sitemaps = append(robots.Sitemaps, value)
+		}
+	}
+	return robots
+}
+
+// productToken returns the name part of a User-Agent header, such as
+// "tsumazirobot" for "TsumaziroBot/1.0 (+https://example.com)", lowercased.
+func productToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	if i := strings.IndexAny(token, " \t"); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
+
+// group returns the group whose user-agent line names the product token of
+// userAgent, falling back to the "*" group.
+func (r *Robots) group(userAgent string) *robotsGroup {
+	if r == nil {
+		return nil
+	}
+	ua := productToken(userAgent)
+	var fallback *robotsGroup
+	for _, g := range r.groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if fallback == nil {
+					fallback = g
+				}
+				continue
+			}
+			if agent != "" && agent == ua {
+				return g
+			}
+		}
+	}
+	return fallback
+}
+
+// Allowed reports whether userAgent may fetch path. The longest matching rule wins
+// and Allow wins ties, as described in RFC 9309.
+func (r *Robots) Allowed(userAgent, path string) bool {
+	g := r.group(userAgent)
+	if g == nil {
+		return true
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range g.rules {
+		if !robotsMatch(rule.path, path) {
+			continue
+		}
+		if len(rule.path) > longest || (len(rule.path) == longest && rule.allow) {
+			longest = len(rule.path)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay requested for userAgent, or zero.
+func (r *Robots) CrawlDelay(userAgent string) time.Duration {
+	g := r.group(userAgent)
+	if g == nil {
+		return 0
+	}
+	return g.crawlDelay
+}
+
+// robotsMatch supports the "*" wildcard and the "$" end anchor.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	parts = parts[1:]
+	if anchored {
+		if len(parts) == 0 {
+			return rest == ""
+		}
+		last := parts[len(parts)-1]
+		if !strings.HasSuffix(rest, last) {
+			return false
+		}
+		rest = rest[:len(rest)-len(last)]
+		parts = parts[:len(parts)-1]
+	}
+	for _, part := range parts {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package batch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: TsumaziroBot
+User-agent: OtherBot
+Disallow: /admin
+Allow: /admin/help
+Disallow: /search?
+Crawl-delay: 0.5
+
+User-agent: evilbot
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	robots := parseRobots(strings.NewReader(testRobots))
+	tests := []struct {
+		userAgent string
+		path      string
+		want      bool
+	}{
+		// The "*" group.
+		{"Mozilla/5.0", "/", true},
+		{"Mozilla/5.0", "/private/data", false},
+		{"Mozilla/5.0", "/private/public/page", true},
+		{"Mozilla/5.0", "/docs/manual.pdf", false},
+		{"Mozilla/5.0", "/docs/manual.pdf?download=1", true},
+		// A named group replaces the "*" group entirely.
+		{"TsumaziroBot/1.0 (+https://example.com/bot)", "/private/data", true},
+		{"tsumazirobot", "/admin/users", false},
+		{"TSUMAZIROBOT/2.0", "/admin", false},
+		// The longest match wins.
+		{"TsumaziroBot/1.0", "/admin/help/faq", true},
+		// Queries are matched as part of the path.
+		{"TsumaziroBot/1.0", "/search?q=faq", false},
+		{"TsumaziroBot/1.0", "/search", true},
+		{"OtherBot/3", "/admin", false},
+		// A group name that is only a substring of the product token does
+		// not match it.
+		{"NotEvilBot/1.0", "/", true},
+		{"EvilBot/1.0", "/", false},
+	}
+	for _, tt := range tests {
+		if got := robots.Allowed(tt.userAgent, tt.path); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.userAgent, tt.path, got, tt.want)
+		}
+	}
+	if want := []string{"https://example.com/sitemap.xml"}; len(robots.Sitemaps) != 1 || robots.Sitemaps[0] != want[0] {
+		t.Errorf("Sitemaps = %v, want %v", robots.Sitemaps, want)
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	robots := parseRobots(strings.NewReader(testRobots))
+	tests := []struct {
+		userAgent string
+		want      time.Duration
+	}{
+		{"Mozilla/5.0", 2 * time.Second},
+		{"TsumaziroBot/1.0", 500 * time.Millisecond},
+		{"EvilBot", 0},
+	}
+	for _, tt := range tests {
+		if got := robots.CrawlDelay(tt.userAgent); got != tt.want {
+			t.Errorf("CrawlDelay(%q) = %v, want %v", tt.userAgent, got, tt.want)
+		}
+	}
+
+	var missing *Robots
+	if !missing.Allowed("TsumaziroBot", "/anything") || missing.CrawlDelay("TsumaziroBot") != 0 {
+		t.Error("a missing robots.txt must allow everything without delay")
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"TsumaziroBot/1.0", "tsumazirobot"},
+		{"TsumaziroBot/1.0 (+https://example.com/bot)", "tsumazirobot"},
+		{"  TsumaziroBot  ", "tsumazirobot"},
+		{"Tsumaziro Bot/1.0", "tsumaziro"},
+		{"TsumaziroBot", "tsumazirobot"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := productToken(tt.in); got != tt.want {
+			t.Errorf("productToken(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish/", "/fish", false},
+		{"/*.php", "/index.php", true},
+		{"/*.php", "/folder/a.php?x=1", true},
+		{"/*.php$", "/a.php", true},
+		{"/*.php$", "/a.php?x=1", false},
+		{"/fish*.php", "/fishheads/catfish.php", true},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"/page$", "/page", true},
+		{"/page$", "/page/", false},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
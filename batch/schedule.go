@@ -0,0 +1,41 @@
+package batch
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// DueCrawlSchedules returns the enabled schedules whose next run is at or before now.
+func DueCrawlSchedules(ctx context.Context, db *db.DB, now time.Time) ([]*model.CrawlSchedule, error) {
+	var schedules []*model.CrawlSchedule
+	if err := db.DB.NewSelect().Model(&schedules).
+		Where("enabled = ?", true).
+		Where("next_run_at <= ?", now).
+		Order("next_run_at ASC").
+		Scan(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return schedules, nil
+}
+
+// AdvanceCrawlSchedule records a run at now and moves the schedule to its next run.
+func AdvanceCrawlSchedule(ctx context.Context, db *db.DB, schedule *model.CrawlSchedule, now time.Time) error {
+	next, err := schedule.NextAfter(now)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	schedule.LastRunAt = &now
+	schedule.NextRunAt = next
+	schedule.UpdatedAt = now
+	if _, err := db.DB.NewUpdate().Model(schedule).
+		Column("last_run_at", "next_run_at", "updated_at").
+		WherePK().
+		Exec(ctx); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package batch
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// Sitemap holds either the page URLs of a <urlset> or the child sitemaps of a <sitemapindex>.
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+func parseSitemap(r io.Reader) (*Sitemap, error) {
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []sitemapLoc `xml:"url"`
+		Sitemaps []sitemapLoc `xml:"sitemap"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	sitemap := &Sitemap{}
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			sitemap.URLs = append(sitemap.URLs, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	return sitemap, nil
+}
@@ -0,0 +1,133 @@
+package batch
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// trackingParams are query parameters that never change page content.
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"fbclid":  true,
+	"yclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+// NormalizeURL strips the fragment and tracking parameters, sorts the query,
+// lowercases the scheme and host and drops default ports so that variants of
+// the same page compare equal.
+func NormalizeURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	host := strings.ToLower(n.Host)
+	if (n.Scheme == "http" && strings.HasSuffix(host, ":80")) || (n.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	n.Host = host
+	n.Fragment = ""
+	n.RawFragment = ""
+	if n.Path == "" {
+		n.Path = "/"
+	}
+
+	query := n.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	// Encode sorts by key.
+	n.RawQuery = query.Encode()
+	n.ForceQuery = false
+	return n.String()
+}
+
+// resolveHref resolves href against base and returns the normalized absolute URL.
+// Non-navigational links such as mailto: and javascript: are rejected.
+func resolveHref(base *url.URL, href string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	u := base.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	return NormalizeURL(u), true
+}
+
+func findHead(node *html.Node) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == atom.Head {
+		return node
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if head := findHead(c); head != nil {
+			return head
+		}
+	}
+	return nil
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// documentBase returns the URL relative links on the page resolve against,
+// honouring <base href> when present.
+func documentBase(node *html.Node, pageURL *url.URL) *url.URL {
+	head := findHead(node)
+	if head == nil {
+		return pageURL
+	}
+	for c := head.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Base {
+			if href := attr(c, "href"); href != "" {
+				if ref, err := url.Parse(href); err == nil {
+					return pageURL.ResolveReference(ref)
+				}
+			}
+		}
+	}
+	return pageURL
+}
+
+// canonicalURL returns the normalized <link rel="canonical"> target, or an empty string.
+func canonicalURL(node *html.Node, base *url.URL) string {
+	head := findHead(node)
+	if head == nil {
+		return ""
+	}
+	for c := head.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Link {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attr(c, "rel"))) {
+			if rel == "canonical" {
+				if u, ok := resolveHref(base, attr(c, "href")); ok {
+					return u
+				}
+			}
+		}
+	}
+	return ""
+}
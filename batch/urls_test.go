@@ -0,0 +1,63 @@
+package batch
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com", "https://example.com/"},
+		{"HTTPS://Example.COM/Help", "https://example.com/Help"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http://example.com:443/a", "http://example.com:443/a"},
+		{"https://example.com/a#section", "https://example.com/a"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"https://example.com/a?b=2&a=1", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/a?a=2&a=1", "https://example.com/a?a=1&a=2"},
+		{"https://example.com/a?utm_source=x&UTM_Medium=y&id=3&gclid=z&FBCLID=w", "https://example.com/a?id=3"},
+		{"https://example.com/%E3%83%98%E3%83%AB%E3%83%97", "https://example.com/%E3%83%98%E3%83%AB%E3%83%97"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NormalizeURL(u); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveHref(t *testing.T) {
+	base, _ := url.Parse("https://example.com/help/faq/index.html")
+	tests := []struct {
+		href string
+		want string
+		ok   bool
+	}{
+		{"other.html", "https://example.com/help/faq/other.html", true},
+		{"../guide/", "https://example.com/help/guide/", true},
+		{"/top?utm_campaign=x", "https://example.com/top", true},
+		{"  https://Example.com/a#b  ", "https://example.com/a", true},
+		{"//cdn.example.com/doc.pdf", "https://cdn.example.com/doc.pdf", true},
+		{"", "", false},
+		{"#top", "", false},
+		{"mailto:help@example.com", "", false},
+		{"javascript:void(0)", "", false},
+		{"tel:0312345678", "", false},
+		{"ftp://example.com/file", "", false},
+		{"http://[::1", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveHref(base, tt.href)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveHref(%q) = %q, %v, want %q, %v", tt.href, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/yamato0211/tsumaziro-faq-server/batch"
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/queue"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+)
+
+type CrawlScheduleRequest struct {
+	Interval string `json:"interval"`
+	Cron     string `json:"cron"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+func newGetCrawlScheduleHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var schedule model.CrawlSchedule
+		if err := db.DB.NewSelect().Model(&schedule).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+			log.Println("Not Found Crawl Schedule: ", err)
+			http.Error(w, "Not Found Crawl Schedule: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(schedule)
+	})
+}
+
+func newPutCrawlScheduleHandler(db *connector.DB, crawlerCfg *cfg.CrawlerConfig) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subDomain := tenant.FromContext(r.Context()).ID
+
+		var req CrawlScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		schedule := &model.CrawlSchedule{
+			AccountID: subDomain,
+			Interval:  req.Interval,
+			Cron:      req.Cron,
+			Enabled:   req.Enabled == nil || *req.Enabled,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		next, err := schedule.NextAfter(now)
+		if err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, "Invalid schedule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Two consecutive runs must be at least MinScheduleInterval apart.
+		if following, _ := schedule.NextAfter(next); following.Sub(next) < crawlerCfg.MinScheduleInterval {
+			http.Error(w, "Invalid schedule: runs more often than every "+crawlerCfg.MinScheduleInterval.String(), http.StatusBadRequest)
+			return
+		}
+		schedule.NextRunAt = next
+
+		if _, err := db.DB.NewInsert().Model(schedule).
+			On("DUPLICATE KEY UPDATE").
+			Set("crawl_interval = VALUES(crawl_interval)").
+			Set("cron = VALUES(cron)").
+			Set("enabled = VALUES(enabled)").
+			Set("next_run_at = VALUES(next_run_at)").
+			Set("updated_at = VALUES(updated_at)").
+			Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(schedule)
+	})
+}
+
+func newTriggerCrawlHandler(db *connector.DB, jobQueue *queue.Queue) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account := tenant.FromContext(r.Context()).Account
+		if account.URL == "" {
+			http.Error(w, "Account has no crawl URL", http.StatusBadRequest)
+			return
+		}
+
+		job, err := jobQueue.EnqueueUnique(r.Context(), model.JobTypeCrawl, account.ID, CrawlData{
+			SubDomain: account.ID,
+			URL:       account.URL,
+		})
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+}
+
+// runScheduledCrawls enqueues a crawl job for every account whose crawl schedule is due.
+func runScheduledCrawls(ctx context.Context, db *connector.DB, jobQueue *queue.Queue) error {
+	now := time.Now()
+	schedules, err := batch.DueCrawlSchedules(ctx, db, now)
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		var account model.Account
+		err := db.DB.NewSelect().Model(&account).Where("id = ?", schedule.AccountID).Scan(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.WithStack(err)
+		}
+		if err == nil && account.URL != "" {
+			if _, err := jobQueue.EnqueueUnique(ctx, model.JobTypeCrawl, account.ID, CrawlData{SubDomain: account.ID, URL: account.URL}); err != nil {
+				return err
+			}
+		}
+		if err := batch.AdvanceCrawlSchedule(ctx, db, schedule, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
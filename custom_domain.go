@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/domainverify"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+)
+
+type CreateCustomDomainRequest struct {
+	Host string `json:"host"`
+}
+
+// CustomDomainResponse tells the account which TXT record proves control of
+// the host.
+type CustomDomainResponse struct {
+	*model.CustomDomain
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+func newCustomDomainResponse(domain *model.CustomDomain) *CustomDomainResponse {
+	return &CustomDomainResponse{
+		CustomDomain: domain,
+		RecordName:   domainverify.RecordName(domain.Host),
+		RecordValue:  domainverify.RecordValue(domain.VerificationToken),
+	}
+}
+
+// findCustomDomain loads the domain named by the {host} path value, scoped to
+// the tenant account.
+func findCustomDomain(r *http.Request, db *connector.DB) (*model.CustomDomain, error) {
+	var domain model.CustomDomain
+	if err := db.DB.NewSelect().Model(&domain).
+		Where("host = ?", tenant.Hostname(r.PathValue("host"))).
+		Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+		Scan(r.Context()); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// ownHost reports whether host is one of our own hosts, which can never be
+// registered as a custom domain.
+func ownHost(tenantCfg *cfg.TenantConfig, host string) bool {
+	if api := tenant.Hostname(tenantCfg.APIHost); api != "" && host == api {
+		return true
+	}
+	base := tenant.Hostname(tenantCfg.BaseDomain)
+	return base != "" && (host == base || strings.HasSuffix(host, "."+base))
+}
+
+func newListCustomDomainsHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domains := []*model.CustomDomain{}
+		if err := db.DB.NewSelect().Model(&domains).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Order("host").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res := make([]*CustomDomainResponse, 0, len(domains))
+		for _, domain := range domains {
+			res = append(res, newCustomDomainResponse(domain))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// newCreateCustomDomainHandler registers an unverified claim on a host for the
+// account and issues the token its TXT record must carry. Claims of other
+// accounts do not block it; only a verified one does.
+func newCreateCustomDomainHandler(db *connector.DB, tenantCfg *cfg.TenantConfig) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateCustomDomainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		host := tenant.Hostname(strings.TrimSpace(req.Host))
+		if err := domainverify.ValidateHost(host); err != nil {
+			http.Error(w, "Invalid host: must be a fully qualified domain name", http.StatusBadRequest)
+			return
+		}
+		if ownHost(tenantCfg, host) {
+			http.Error(w, "Invalid host: use the sub domain of the account instead", http.StatusBadRequest)
+			return
+		}
+
+		verified, err := db.DB.NewSelect().Model((*model.CustomDomain)(nil)).Where("verified_host = ?", host).Exists(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if verified {
+			http.Error(w, "Host is already in use", http.StatusConflict)
+			return
+		}
+
+		verificationToken, err := token.Generate(16)
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		domain := &model.CustomDomain{
+			Host:              host,
+			AccountID:         tenant.FromContext(r.Context()).ID,
+			VerificationToken: verificationToken,
+			ExpiresAt:         now.Add(tenantCfg.DomainClaimTTL),
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+		err = db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			// Lapsed claims on the host are dropped so that they can be
+			// registered again.
+			if _, err := tx.NewDelete().Model((*model.CustomDomain)(nil)).
+				Where("host = ?", host).
+				Where("verified_at IS NULL").
+				Where("expires_at < ?", now).
+				Exec(ctx); err != nil {
+				return err
+			}
+			_, err := tx.NewInsert().Model(domain).Exec(ctx)
+			return err
+		})
+		if err != nil {
+			if connector.IsDuplicateEntry(err) {
+				http.Error(w, "Host is already registered for this account", http.StatusConflict)
+				return
+			}
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newCustomDomainResponse(domain))
+	})
+}
+
+// newVerifyCustomDomainHandler looks up the TXT record of the host and, when it
+// carries the issued token, starts serving the account on that host. The
+// first account to verify a host wins it; the other claims on it are dropped.
+func newVerifyCustomDomainHandler(db *connector.DB, verifier *domainverify.Verifier, resolver *tenant.Resolver) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain, err := findCustomDomain(r, db)
+		if err != nil {
+			log.Println("Not Found Custom Domain: ", err)
+			http.Error(w, "Not Found Custom Domain", http.StatusNotFound)
+			return
+		}
+		if domain.Expired(time.Now()) {
+			http.Error(w, "Claim has expired: register the host again", http.StatusGone)
+			return
+		}
+		if domain.VerifiedAt == nil {
+			if err := verifier.Verify(r.Context(), domain.Host, domain.VerificationToken); err != nil {
+				if errors.Is(err, domainverify.ErrNotVerified) {
+					http.Error(w, "Verification failed: TXT record "+domainverify.RecordName(domain.Host)+" does not contain "+domainverify.RecordValue(domain.VerificationToken), http.StatusConflict)
+					return
+				}
+				log.Println("Error: lookup TXT record: ", err)
+				http.Error(w, "Verification failed: "+err.Error(), http.StatusBadGateway)
+				return
+			}
+			now := time.Now()
+			domain.VerifiedAt = &now
+			domain.VerifiedHost = &domain.Host
+			domain.UpdatedAt = now
+			err := db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+				if _, err := tx.NewUpdate().Model(domain).Column("verified_host", "verified_at", "updated_at").WherePK().Exec(ctx); err != nil {
+					return err
+				}
+				_, err := tx.NewDelete().Model((*model.CustomDomain)(nil)).
+					Where("host = ?", domain.Host).
+					Where("verified_at IS NULL").
+					Exec(ctx)
+				return err
+			})
+			if err != nil {
+				if connector.IsDuplicateEntry(err) {
+					http.Error(w, "Host is already in use", http.StatusConflict)
+					return
+				}
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resolver.Forget(domain.Host)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(newCustomDomainResponse(domain))
+	})
+}
+
+func newDeleteCustomDomainHandler(db *connector.DB, resolver *tenant.Resolver) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain, err := findCustomDomain(r, db)
+		if err != nil {
+			log.Println("Not Found Custom Domain: ", err)
+			http.Error(w, "Not Found Custom Domain", http.StatusNotFound)
+			return
+		}
+		if _, err := db.DB.NewDelete().Model(domain).WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if domain.VerifiedHost != nil {
+			resolver.Forget(*domain.VerifiedHost)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -12,14 +12,18 @@ import (
 
 type Account struct {
 	bun.BaseModel `bun:"table:users,alias:u"`
-	ID            string          `bun:",pk"`
-	Name          string          `bun:"name,notnull"`
-	Email         string          `bun:"email,unique"`
-	ProjectID     string          `bun:"project_id,unique,notnull"`
-	Faqs          json.RawMessage `bun:"faqs,type:json"`
-	FirebaseID    string          `bun:"firebase_id,unique"`
-	CreatedAt     time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp"`
-	UpdatedAt     time.Time       `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	ID            string          `bun:",pk" json:"id"`
+	Name          string          `bun:"name,notnull" json:"name"`
+	Email         string          `bun:"email,unique" json:"email"`
+	ProjectID     string          `bun:"project_id,unique,nullzero" json:"project_id"`
+	URL           string          `bun:"url" json:"url"`
+	Faqs          json.RawMessage `bun:"faqs,type:json" json:"-"` // Deprecated: FAQs are stored in the faqs table.
+	FirebaseID    string          `bun:"firebase_id,unique" json:"-"`
+	BlockedTerms  []string        `bun:"blocked_terms,type:json" json:"blocked_terms,omitempty"`
+	WebhookURL    string          `bun:"webhook_url" json:"webhook_url,omitempty"`
+	WebhookSecret string          `bun:"webhook_secret" json:"-"`
+	CreatedAt     time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time       `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
 
 func (a *Account) String() string {
@@ -27,8 +31,47 @@ func (a *Account) String() string {
 }
 
 func MigrateAccount(db *db.DB) error {
-	if _, err := db.NewCreateTable().Model(&Account{}).IfNotExists().Exec(context.Background()); err != nil {
+	ctx := context.Background()
+	if _, err := db.NewCreateTable().Model(&Account{}).IfNotExists().Exec(ctx); err != nil {
+		return err
+	}
+	// CREATE TABLE IF NOT EXISTS leaves an existing users table alone, so
+	// columns added after the first release are added here.
+	for _, column := range []struct{ name, definition string }{
+		{"url", "VARCHAR(255)"},
+		{"blocked_terms", "JSON"},
+		{"webhook_url", "VARCHAR(255)"},
+		{"webhook_secret", "VARCHAR(255)"},
+	} {
+		if err := addColumnIfNotExists(ctx, db, "users", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	// Accounts created from a website URL alone have no Scrapbox project. It
+	// is stored as NULL so that they do not collide on the unique key.
+	if _, err := db.NewRaw("ALTER TABLE ? MODIFY ? VARCHAR(255) NULL", bun.Ident("users"), bun.Ident("project_id")).Exec(ctx); err != nil {
+		return err
+	}
+	if _, err := db.NewUpdate().Model((*Account)(nil)).Set("project_id = NULL").Where("project_id = ''").Exec(ctx); err != nil {
 		return err
 	}
 	return nil
 }
+
+// addColumnIfNotExists adds a column to table unless the table already has it.
+func addColumnIfNotExists(ctx context.Context, db *db.DB, table, column, definition string) error {
+	exists, err := db.NewSelect().
+		TableExpr("information_schema.columns").
+		Where("table_schema = DATABASE()").
+		Where("table_name = ?", table).
+		Where("column_name = ?", column).
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err = db.NewRaw("ALTER TABLE ? ADD COLUMN ? "+definition, bun.Ident(table), bun.Ident(column)).Exec(ctx)
+	return err
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// API key scopes. Read covers the admin endpoints that only read, chat covers
+// the chat endpoint and admin covers everything an editor can do, reads
+// included.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeChat  = "chat"
+	APIKeyScopeAdmin = "admin"
+)
+
+func ValidAPIKeyScope(scope string) bool {
+	return scope == APIKeyScopeRead || scope == APIKeyScopeChat || scope == APIKeyScopeAdmin
+}
+
+// APIKey lets a server act on an account without a Firebase user. Only the
+// SHA-256 hash of the key is stored; Prefix identifies it in lists and logs.
+type APIKey struct {
+	bun.BaseModel `bun:"table:api_keys,alias:ak"`
+	ID            int64      `bun:",pk,autoincrement" json:"id"`
+	AccountID     string     `bun:"account_id,notnull" json:"-"`
+	Name          string     `bun:"name,notnull" json:"name"`
+	Prefix        string     `bun:"prefix,unique,notnull" json:"prefix"`
+	Hash          string     `bun:"hash,notnull" json:"-"`
+	Scopes        []string   `bun:"scopes,type:json" json:"scopes"`
+	CreatedBy     string     `bun:"created_by" json:"-"`
+	LastUsedAt    *time.Time `bun:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope. The admin scope implies read.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || (s == APIKeyScopeAdmin && scope == APIKeyScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+func MigrateAPIKey(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&APIKey{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/cron"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// CrawlSchedule re-crawls an account's URL either every Interval (a Go
+// duration such as "24h") or on a five-field Cron expression.
+type CrawlSchedule struct {
+	bun.BaseModel `bun:"table:crawl_schedules,alias:csc"`
+	AccountID     string     `bun:"account_id,pk" json:"account_id"`
+	Interval      string     `bun:"crawl_interval" json:"interval,omitempty"`
+	Cron          string     `bun:"cron" json:"cron,omitempty"`
+	Enabled       bool       `bun:"enabled,notnull" json:"enabled"`
+	NextRunAt     time.Time  `bun:"next_run_at,nullzero" json:"next_run_at"`
+	LastRunAt     *time.Time `bun:"last_run_at" json:"last_run_at,omitempty"`
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// NextAfter returns the first run time after t.
+func (s *CrawlSchedule) NextAfter(t time.Time) (time.Time, error) {
+	switch {
+	case s.Interval != "" && s.Cron != "":
+		return time.Time{}, errors.New("only one of interval and cron can be set")
+	case s.Interval != "":
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if d <= 0 {
+			return time.Time{}, errors.New("interval must be positive")
+		}
+		return t.Add(d), nil
+	case s.Cron != "":
+		sched, err := cron.Parse(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := sched.Next(t)
+		if next.IsZero() {
+			return time.Time{}, errors.New("cron expression never matches")
+		}
+		return next, nil
+	default:
+		return time.Time{}, errors.New("either interval or cron is required")
+	}
+}
+
+func MigrateCrawlSchedule(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&CrawlSchedule{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
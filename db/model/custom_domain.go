@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// CustomDomain is an account's claim on a host outside our base domain, such
+// as help.example.com. Any number of accounts may claim the same host, but a
+// claim only serves the account once it is verified with a DNS TXT record
+// carrying VerificationToken. VerifiedHost is set on verification and is
+// unique, so only one account can ever hold a host. Unverified claims lapse
+// at ExpiresAt.
+type CustomDomain struct {
+	bun.BaseModel     `bun:"table:custom_domains,alias:cd"`
+	ID                int64      `bun:",pk,autoincrement" json:"-"`
+	Host              string     `bun:"host,notnull,unique:custom_domains_host_account" json:"host"`
+	AccountID         string     `bun:"account_id,notnull,unique:custom_domains_host_account" json:"-"`
+	VerificationToken string     `bun:"verification_token,notnull" json:"verification_token"`
+	VerifiedHost      *string    `bun:"verified_host,unique" json:"-"`
+	VerifiedAt        *time.Time `bun:"verified_at" json:"verified_at,omitempty"`
+	ExpiresAt         time.Time  `bun:"expires_at,notnull" json:"expires_at"`
+	CreatedAt         time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt         time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Expired reports whether an unverified claim has lapsed.
+func (d *CustomDomain) Expired(now time.Time) bool {
+	return d.VerifiedAt == nil && now.After(d.ExpiresAt)
+}
+
+func MigrateCustomDomain(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&CustomDomain{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// Generated entries start as drafts and are only published once approved.
+const (
+	FAQStatusDraft    = "draft"
+	FAQStatusApproved = "approved"
+	FAQStatusHidden   = "hidden"
+)
+
+const (
+	FAQSourceScrapbox = "scrapbox"
+	FAQSourceCrawl    = "crawl"
+	FAQSourceManual   = "manual"
+)
+
+// FAQ is a single question and answer belonging to an account. Fingerprint
+// identifies the question the entry was created with and is not changed by
+// edits, so regenerating never duplicates or overwrites a reviewed or manually
+// written entry. Source tells generated entries from manual ones.
+type FAQ struct {
+	bun.BaseModel `bun:"table:faqs,alias:f"`
+	ID            int64     `bun:",pk,autoincrement" json:"id"`
+	AccountID     string    `bun:"account_id,notnull,unique:faqs_account_fingerprint" json:"-"`
+	Fingerprint   string    `bun:"fingerprint,notnull,unique:faqs_account_fingerprint" json:"-"`
+	Question      string    `bun:"question,type:text,notnull" json:"question"`
+	Answer        string    `bun:"answer,type:text" json:"answer"`
+	Status        string    `bun:"status,notnull" json:"status"`
+	Source        string    `bun:"source,notnull" json:"source"`
+	SourceURL     string    `bun:"source_url,type:text" json:"source_url,omitempty"`
+	PageTitle     string    `bun:"page_title" json:"pageTitle,omitempty"`
+	Tags          []string  `bun:"tags,type:json" json:"tags,omitempty"`
+	Position      int       `bun:"position,notnull" json:"position"`
+	MergedIntoID  *int64    `bun:"merged_into_id" json:"merged_into_id,omitempty"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func FAQFingerprint(question string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(question), " "))))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeTags trims tags, drops a leading "#" and removes empty and
+// case-insensitive duplicate entries, keeping the first spelling.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func MigrateFAQ(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&FAQ{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// legacyFAQ is an entry of the users.faqs JSON column FAQs used to be kept in.
+type legacyFAQ struct {
+	Question  string `json:"question"`
+	PageTitle string `json:"pageTitle"`
+}
+
+// MigrateLegacyFAQs copies the FAQs stored in the deprecated users.faqs
+// column into the faqs table as approved entries, since they were already
+// public. Entries the table already has are left untouched, so it is safe to
+// run more than once.
+func MigrateLegacyFAQs(db *db.DB) error {
+	ctx := context.Background()
+	var accounts []*Account
+	if err := db.NewSelect().Model(&accounts).Column("id", "faqs").Where("faqs IS NOT NULL").Scan(ctx); err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		var legacy []legacyFAQ
+		if err := json.Unmarshal(account.Faqs, &legacy); err != nil {
+			return fmt.Errorf("account %s: %w", account.ID, err)
+		}
+		var faqs []*FAQ
+		seen := map[string]bool{}
+		for _, entry := range legacy {
+			question := strings.TrimSpace(entry.Question)
+			fingerprint := FAQFingerprint(question)
+			if question == "" || seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			faqs = append(faqs, &FAQ{
+				AccountID:   account.ID,
+				Fingerprint: fingerprint,
+				Question:    question,
+				Status:      FAQStatusApproved,
+				Source:      FAQSourceScrapbox,
+				PageTitle:   entry.PageTitle,
+				Position:    len(faqs),
+			})
+		}
+		if len(faqs) == 0 {
+			continue
+		}
+		if _, err := db.NewInsert().Model(&faqs).Ignore().Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
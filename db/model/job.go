@@ -0,0 +1,52 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+const (
+	JobTypeCrawl       = "crawl"
+	JobTypeGenerateFAQ = "generate_faq"
+)
+
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateDead      = "dead"
+)
+
+type Job struct {
+	bun.BaseModel `bun:"table:jobs,alias:j"`
+	ID            int64  `bun:",pk,autoincrement" json:"id"`
+	Type          string `bun:"type,notnull" json:"type"`
+	AccountID     string `bun:"account_id,notnull" json:"account_id"`
+	// DedupeKey is set while a job enqueued with EnqueueUnique is queued or
+	// running, so that the database rejects a second one.
+	DedupeKey      *string         `bun:"dedupe_key,unique" json:"-"`
+	Payload        json.RawMessage `bun:"payload,type:json" json:"-"`
+	State          string          `bun:"state,notnull" json:"state"`
+	Attempts       int             `bun:"attempts,notnull" json:"attempts"`
+	MaxAttempts    int             `bun:"max_attempts,notnull" json:"max_attempts"`
+	RunAt          time.Time       `bun:"run_at,notnull" json:"run_at"`
+	LeasedBy       string          `bun:"leased_by" json:"-"`
+	LeaseExpiresAt *time.Time      `bun:"lease_expires_at" json:"-"`
+	LastError      string          `bun:"last_error,type:text" json:"error,omitempty"`
+	PagesProcessed int             `bun:"pages_processed,notnull" json:"pages_processed"`
+	StartedAt      *time.Time      `bun:"started_at" json:"started_at,omitempty"`
+	FinishedAt     *time.Time      `bun:"finished_at" json:"finished_at,omitempty"`
+	CreatedAt      time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt      time.Time       `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func MigrateJob(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&Job{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
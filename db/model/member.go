@@ -0,0 +1,83 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// Roles a Firebase user can hold in an account. Owners manage members,
+// domains and the account itself, editors manage FAQs and crawls, viewers
+// can only read.
+const (
+	MemberRoleOwner  = "owner"
+	MemberRoleEditor = "editor"
+	MemberRoleViewer = "viewer"
+)
+
+var memberRoleRanks = map[string]int{
+	MemberRoleViewer: 1,
+	MemberRoleEditor: 2,
+	MemberRoleOwner:  3,
+}
+
+func ValidMemberRole(role string) bool {
+	return memberRoleRanks[role] > 0
+}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	return ValidMemberRole(role) && memberRoleRanks[role] >= memberRoleRanks[min]
+}
+
+// Member gives a Firebase user a role in an account.
+type Member struct {
+	bun.BaseModel `bun:"table:account_members,alias:am"`
+	ID            int64     `bun:",pk,autoincrement" json:"id"`
+	AccountID     string    `bun:"account_id,notnull,unique:account_members_account_user" json:"-"`
+	FirebaseID    string    `bun:"firebase_id,notnull,unique:account_members_account_user" json:"-"`
+	Email         string    `bun:"email" json:"email"`
+	Role          string    `bun:"role,notnull" json:"role"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Invitation lets whoever signs in with Email join the account with Role by
+// presenting the token hashed into TokenHash before ExpiresAt.
+type Invitation struct {
+	bun.BaseModel `bun:"table:account_invitations,alias:ai"`
+	ID            int64      `bun:",pk,autoincrement" json:"id"`
+	AccountID     string     `bun:"account_id,notnull" json:"account_id"`
+	Email         string     `bun:"email,notnull" json:"email"`
+	Role          string     `bun:"role,notnull" json:"role"`
+	TokenHash     string     `bun:"token_hash,unique,notnull" json:"-"`
+	InvitedBy     string     `bun:"invited_by" json:"-"`
+	ExpiresAt     time.Time  `bun:"expires_at,notnull" json:"expires_at"`
+	AcceptedAt    *time.Time `bun:"accepted_at" json:"accepted_at,omitempty"`
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// MigrateMember creates the account_members table and makes the Firebase user
+// of every existing account its owner.
+func MigrateMember(db *db.DB) error {
+	ctx := context.Background()
+	if _, err := db.NewCreateTable().Model(&Member{}).IfNotExists().Exec(ctx); err != nil {
+		return err
+	}
+	owners := db.NewSelect().Model((*Account)(nil)).
+		ColumnExpr("u.id, u.firebase_id, u.email, ?", MemberRoleOwner).
+		Where("u.firebase_id IS NOT NULL AND u.firebase_id <> ''")
+	if _, err := db.NewRaw("INSERT IGNORE INTO account_members (account_id, firebase_id, email, role) ?", owners).Exec(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func MigrateInvitation(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&Invitation{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
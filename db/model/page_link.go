@@ -0,0 +1,25 @@
+package model
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// PageLink is an edge of an account's Scrapbox link graph: Source links to
+// Target through a [bracket link] or #hashtag.
+type PageLink struct {
+	bun.BaseModel `bun:"table:page_links,alias:pl"`
+	ID            int64  `bun:",pk,autoincrement"`
+	AccountID     string `bun:"account_id,notnull"`
+	Source        string `bun:"source,type:text,notnull"`
+	Target        string `bun:"target,type:text,notnull"`
+}
+
+func MigratePageLink(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&PageLink{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+type ChatSession struct {
+	bun.BaseModel `bun:"table:chat_sessions,alias:cs"`
+	ID            string    `bun:",pk"`
+	AccountID     string    `bun:"account_id,notnull"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+type ChatMessage struct {
+	bun.BaseModel `bun:"table:chat_messages,alias:cm"`
+	ID            int64     `bun:",pk,autoincrement" json:"-"`
+	SessionID     string    `bun:"session_id,notnull" json:"-"`
+	Role          string    `bun:"role,notnull" json:"role"`
+	Text          string    `bun:"text,type:text" json:"text"`
+	CreatedAt     time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+func MigrateChatSession(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&ChatSession{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func MigrateChatMessage(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&ChatMessage{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
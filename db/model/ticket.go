@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+const (
+	TicketStatusOpen       = "open"
+	TicketStatusInProgress = "in_progress"
+	TicketStatusResolved   = "resolved"
+)
+
+func ValidTicketStatus(status string) bool {
+	return status == TicketStatusOpen || status == TicketStatusInProgress || status == TicketStatusResolved
+}
+
+type Ticket struct {
+	bun.BaseModel `bun:"table:tickets,alias:t"`
+	ID            string          `bun:",pk" json:"id"`
+	AccountID     string          `bun:"account_id,notnull" json:"-"`
+	SessionID     string          `bun:"session_id,notnull" json:"session_id"`
+	Status        string          `bun:"status,notnull" json:"status"`
+	Reason        string          `bun:"reason,notnull" json:"reason"`
+	Transcript    json.RawMessage `bun:"transcript,type:json" json:"-"`
+	CreatedAt     time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time       `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func MigrateTicket(db *db.DB) error {
+	if _, err := db.NewCreateTable().Model(&Ticket{}).IfNotExists().Exec(context.Background()); err != nil {
+		return err
+	}
+	return nil
+}
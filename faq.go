@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/batch"
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+)
+
+type CreateFAQRequest struct {
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Tags     []string `json:"tags"`
+	Position int      `json:"position"`
+	Status   string   `json:"status"`
+}
+
+type UpdateFAQRequest struct {
+	Question *string   `json:"question"`
+	Answer   *string   `json:"answer"`
+	Tags     *[]string `json:"tags"`
+	Position *int      `json:"position"`
+}
+
+type MergeFAQRequest struct {
+	FAQIDs []int64 `json:"faq_ids"`
+	UpdateFAQRequest
+}
+
+type FAQCategory struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+var faqEditColumns = []string{"question", "answer", "tags", "position", "updated_at"}
+
+// faqCategories counts how many FAQs carry each tag, most used first.
+func faqCategories(faqs []*model.FAQ) []*FAQCategory {
+	categories := []*FAQCategory{}
+	index := map[string]*FAQCategory{}
+	for _, faq := range faqs {
+		for _, tag := range faq.Tags {
+			key := strings.ToLower(tag)
+			category, ok := index[key]
+			if !ok {
+				category = &FAQCategory{Name: tag}
+				index[key] = category
+				categories = append(categories, category)
+			}
+			category.Count++
+		}
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Count != categories[j].Count {
+			return categories[i].Count > categories[j].Count
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories
+}
+
+func hasTag(faq *model.FAQ, name string) bool {
+	for _, tag := range faq.Tags {
+		if strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findFAQ loads the FAQ named by the {faqID} path value, scoped to the tenant account.
+func findFAQ(r *http.Request, db *connector.DB) (*model.FAQ, error) {
+	faqID, err := strconv.ParseInt(r.PathValue("faqID"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var faq model.FAQ
+	if err := db.DB.NewSelect().Model(&faq).Where("id = ?", faqID).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+		return nil, err
+	}
+	return &faq, nil
+}
+
+func newListFAQsHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := db.DB.NewSelect().Model((*model.FAQ)(nil)).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Order("position", "id")
+		if status := r.URL.Query().Get("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if source := r.URL.Query().Get("source"); source != "" {
+			query = query.Where("source = ?", source)
+		}
+		faqs := []*model.FAQ{}
+		if err := query.Scan(r.Context(), &faqs); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if category := r.URL.Query().Get("category"); category != "" {
+			filtered := []*model.FAQ{}
+			for _, faq := range faqs {
+				if hasTag(faq, category) {
+					filtered = append(filtered, faq)
+				}
+			}
+			faqs = filtered
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(faqs)
+	})
+}
+
+func newGetFAQHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		faq, err := findFAQ(r, db)
+		if err != nil {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(faq)
+	})
+}
+
+const (
+	defaultRelatedLimit = 5
+	maxRelatedLimit     = 20
+)
+
+// newFAQCategoriesHandler lists the categories of the published FAQs with how
+// many FAQs each has.
+func newFAQCategoriesHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var faqs []*model.FAQ
+		if err := db.DB.NewSelect().Model(&faqs).
+			Column("id", "tags").
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Where("status = ?", model.FAQStatusApproved).
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(faqCategories(faqs))
+	})
+}
+
+// newRelatedFAQsHandler returns the published FAQs related to a published FAQ,
+// ranked by link proximity of their Scrapbox pages and shared tags.
+func newRelatedFAQsHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		faq, err := findFAQ(r, db)
+		if err != nil || faq.Status != model.FAQStatusApproved {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ", http.StatusNotFound)
+			return
+		}
+		limit := defaultRelatedLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 || limit > maxRelatedLimit {
+				http.Error(w, "Invalid limit: must be between 1 and "+strconv.Itoa(maxRelatedLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var candidates []*model.FAQ
+		if err := db.DB.NewSelect().Model(&candidates).
+			Where("account_id = ?", faq.AccountID).
+			Where("status = ?", model.FAQStatusApproved).
+			Order("position", "id").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var links []*model.PageLink
+		if faq.PageTitle != "" {
+			if err := db.DB.NewSelect().Model(&links).Where("account_id = ?", faq.AccountID).Scan(r.Context()); err != nil {
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(batch.RankRelatedFAQs(faq, candidates, links, limit))
+	})
+}
+
+// newCreateFAQHandler stores a manually written FAQ. Manual entries are
+// published immediately unless another status is requested.
+func newCreateFAQHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subDomain := tenant.FromContext(r.Context()).ID
+
+		var req CreateFAQRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		question := strings.TrimSpace(req.Question)
+		if question == "" {
+			http.Error(w, "Question must not be empty", http.StatusBadRequest)
+			return
+		}
+		switch req.Status {
+		case "":
+			req.Status = model.FAQStatusApproved
+		case model.FAQStatusDraft, model.FAQStatusApproved, model.FAQStatusHidden:
+		default:
+			http.Error(w, "Invalid status: "+req.Status, http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		faq := &model.FAQ{
+			AccountID:   subDomain,
+			Fingerprint: model.FAQFingerprint(question),
+			Question:    question,
+			Answer:      strings.TrimSpace(req.Answer),
+			Status:      req.Status,
+			Source:      model.FAQSourceManual,
+			Tags:        model.NormalizeTags(req.Tags),
+			Position:    req.Position,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		res, err := db.DB.NewInsert().Model(faq).Ignore().Exec(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if added, _ := res.RowsAffected(); added == 0 {
+			http.Error(w, "FAQ with the same question already exists", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(faq)
+	})
+}
+
+// newDeleteFAQHandler deletes a manually written FAQ. Generated entries are
+// hidden instead, since deleting them would let the next generation run add
+// them again.
+func newDeleteFAQHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		faq, err := findFAQ(r, db)
+		if err != nil {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if faq.Source != model.FAQSourceManual {
+			http.Error(w, "Generated FAQs cannot be deleted; hide them instead", http.StatusConflict)
+			return
+		}
+
+		err = db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			// Entries merged into this one are no longer merged into anything.
+			if _, err := tx.NewUpdate().Model((*model.FAQ)(nil)).
+				Set("merged_into_id = NULL").
+				Where("merged_into_id = ?", faq.ID).
+				Exec(ctx); err != nil {
+				return err
+			}
+			_, err := tx.NewDelete().Model(faq).WherePK().Exec(ctx)
+			return err
+		})
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func newUpdateFAQHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		faq, err := findFAQ(r, db)
+		if err != nil {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		var req UpdateFAQRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Question != nil && strings.TrimSpace(*req.Question) == "" {
+			http.Error(w, "Question must not be empty", http.StatusBadRequest)
+			return
+		}
+		applyFAQEdit(faq, &req)
+
+		if _, err := db.DB.NewUpdate().Model(faq).Column(faqEditColumns...).WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(faq)
+	})
+}
+
+func applyFAQEdit(faq *model.FAQ, req *UpdateFAQRequest) {
+	if req.Question != nil {
+		faq.Question = strings.TrimSpace(*req.Question)
+	}
+	if req.Answer != nil {
+		faq.Answer = strings.TrimSpace(*req.Answer)
+	}
+	if req.Tags != nil {
+		faq.Tags = model.NormalizeTags(*req.Tags)
+	}
+	if req.Position != nil {
+		faq.Position = *req.Position
+	}
+	faq.UpdatedAt = time.Now()
+}
+
+// newSetFAQStatusHandler moves an FAQ to status, e.g. to publish or hide it.
+func newSetFAQStatusHandler(db *connector.DB, status string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		faq, err := findFAQ(r, db)
+		if err != nil {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if status == model.FAQStatusApproved && faq.MergedIntoID != nil {
+			http.Error(w, "FAQ has been merged into another entry", http.StatusConflict)
+			return
+		}
+
+		faq.Status = status
+		faq.UpdatedAt = time.Now()
+		if _, err := db.DB.NewUpdate().Model(faq).Column("status", "updated_at").WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(faq)
+	})
+}
+
+// newMergeFAQHandler folds duplicate entries into the FAQ in the path. The
+// duplicates are hidden rather than deleted so regeneration does not bring
+// them back.
+func newMergeFAQHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target, err := findFAQ(r, db)
+		if err != nil {
+			log.Println("Not Found FAQ: ", err)
+			http.Error(w, "Not Found FAQ: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if target.MergedIntoID != nil {
+			http.Error(w, "FAQ has been merged into another entry", http.StatusConflict)
+			return
+		}
+
+		var req MergeFAQRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.FAQIDs) == 0 {
+			http.Error(w, "faq_ids must not be empty", http.StatusBadRequest)
+			return
+		}
+		ids := make([]int64, 0, len(req.FAQIDs))
+		seen := map[int64]bool{}
+		for _, id := range req.FAQIDs {
+			if id == target.ID {
+				http.Error(w, "Cannot merge an FAQ into itself", http.StatusBadRequest)
+				return
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		req.FAQIDs = ids
+		if req.Question != nil && strings.TrimSpace(*req.Question) == "" {
+			http.Error(w, "Question must not be empty", http.StatusBadRequest)
+			return
+		}
+
+		count, err := db.DB.NewSelect().Model((*model.FAQ)(nil)).
+			Where("account_id = ?", target.AccountID).
+			Where("id IN (?)", bun.In(req.FAQIDs)).
+			Count(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != len(req.FAQIDs) {
+			http.Error(w, "Not Found FAQ", http.StatusNotFound)
+			return
+		}
+
+		applyFAQEdit(target, &req.UpdateFAQRequest)
+		err = db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			// Entries already merged into one of the duplicates follow it.
+			if _, err := tx.NewUpdate().Model((*model.FAQ)(nil)).
+				Set("merged_into_id = ?", target.ID).
+				Set("updated_at = ?", target.UpdatedAt).
+				Where("account_id = ?", target.AccountID).
+				Where("merged_into_id IN (?)", bun.In(req.FAQIDs)).
+				Exec(ctx); err != nil {
+				return err
+			}
+			if _, err := tx.NewUpdate().Model((*model.FAQ)(nil)).
+				Set("status = ?", model.FAQStatusHidden).
+				Set("merged_into_id = ?", target.ID).
+				Set("updated_at = ?", target.UpdatedAt).
+				Where("account_id = ?", target.AccountID).
+				Where("id IN (?)", bun.In(req.FAQIDs)).
+				Exec(ctx); err != nil {
+				return err
+			}
+			_, err := tx.NewUpdate().Model(target).Column(faqEditColumns...).WherePK().Exec(ctx)
+			return err
+		})
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(target)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/webhook"
+)
+
+const ticketCreatedEvent = "ticket.created"
+
+type TicketNotification struct {
+	TicketID   string               `json:"ticket_id"`
+	SessionID  string               `json:"session_id"`
+	Reason     string               `json:"reason"`
+	Transcript []*model.ChatMessage `json:"transcript"`
+}
+
+// openTicket persists a support ticket with the session transcript and notifies
+// the account owner's webhook in the background. A session that already has an
+// unresolved ticket keeps it: only its transcript is brought up to date, and no
+// new notification is sent.
+func openTicket(ctx context.Context, db *connector.DB, notifier *webhook.Client, account *model.Account, sessionID, reason string) (*model.Ticket, error) {
+	var messages []*model.ChatMessage
+	if err := db.DB.NewSelect().Model(&messages).Where("session_id = ?", sessionID).Order("id ASC").Scan(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	transcript, err := json.Marshal(messages)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var current model.Ticket
+	err = db.DB.NewSelect().Model(&current).
+		Where("account_id = ?", account.ID).
+		Where("session_id = ?", sessionID).
+		Where("status IN (?)", bun.In([]string{model.TicketStatusOpen, model.TicketStatusInProgress})).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == nil {
+		current.Transcript = transcript
+		current.UpdatedAt = time.Now()
+		if _, err := db.DB.NewUpdate().Model(&current).Column("transcript", "updated_at").WherePK().Exec(ctx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &current, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(err)
+	}
+
+	id, err := token.Generate(16)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ticket := &model.Ticket{
+		ID:         id,
+		AccountID:  account.ID,
+		SessionID:  sessionID,
+		Status:     model.TicketStatusOpen,
+		Reason:     reason,
+		Transcript: transcript,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if _, err := db.DB.NewInsert().Model(ticket).Exec(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if account.WebhookURL != "" {
+		event := webhook.Event{
+			Type:      ticketCreatedEvent,
+			AccountID: account.ID,
+			Data: TicketNotification{
+				TicketID:   ticket.ID,
+				SessionID:  sessionID,
+				Reason:     reason,
+				Transcript: messages,
+			},
+			SentAt: time.Now(),
+		}
+		go func(url string) {
+			if err := notifier.Send(context.Background(), url, account.WebhookSecret, event); err != nil {
+				log.Println("Error: notify ticket webhook: ", err)
+			}
+		}(account.WebhookURL)
+	}
+	return ticket, nil
+}
+
+func newTicketHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subDomain := tenant.FromContext(r.Context()).ID
+		ticketID := r.PathValue("ticketID")
+		var ticket model.Ticket
+		if err := db.DB.NewSelect().Model(&ticket).Where("id = ?", ticketID).Where("account_id = ?", subDomain).Scan(r.Context()); err != nil {
+			log.Println("Not Found Ticket: ", err)
+			http.Error(w, "Not Found Ticket: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ticket)
+	})
+}
+
+type UpdateTicketRequest struct {
+	Status string `json:"status"`
+}
+
+// newUpdateTicketHandler moves a ticket through open, in_progress and
+// resolved. Once resolved, the next handoff in the session opens a new one.
+func newUpdateTicketHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req UpdateTicketRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !model.ValidTicketStatus(req.Status) {
+			http.Error(w, "Invalid status: must be open, in_progress or resolved", http.StatusBadRequest)
+			return
+		}
+		var ticket model.Ticket
+		if err := db.DB.NewSelect().Model(&ticket).Where("id = ?", r.PathValue("ticketID")).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+			log.Println("Not Found Ticket: ", err)
+			http.Error(w, "Not Found Ticket: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		ticket.Status = req.Status
+		ticket.UpdatedAt = time.Now()
+		if _, err := db.DB.NewUpdate().Model(&ticket).Column("status", "updated_at").WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ticket)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/yamato0211/tsumaziro-faq-server/batch"
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/queue"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/storage"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+)
+
+func newCrawlJobHandler(db *connector.DB, store storage.BlobStore, crawlerCfg *cfg.CrawlerConfig) queue.HandlerFunc {
+	return func(ctx context.Context, job *model.Job) error {
+		var data CrawlData
+		if err := json.Unmarshal(job.Payload, &data); err != nil {
+			return errors.WithStack(err)
+		}
+		manifest, err := batch.CrawlKnowledge(ctx, data.URL, data.SubDomain, store, crawlerCfg)
+		if err != nil {
+			return err
+		}
+		job.PagesProcessed = len(manifest.Changes.Added) + len(manifest.Changes.Changed) + len(manifest.Changes.Unchanged)
+		log.Printf("crawled %s into %s: %d added, %d changed, %d removed, %d unchanged\n",
+			data.URL, manifest.Prefix,
+			len(manifest.Changes.Added), len(manifest.Changes.Changed),
+			len(manifest.Changes.Removed), len(manifest.Changes.Unchanged))
+
+		candidates, err := batch.StoreFAQCandidates(db, ctx, job.AccountID, manifest)
+		if err != nil {
+			return err
+		}
+		log.Printf("stored %d new FAQ candidates from %s\n", candidates, data.URL)
+		return nil
+	}
+}
+
+func newGenerateFAQJobHandler(db *connector.DB) queue.HandlerFunc {
+	return func(ctx context.Context, job *model.Job) error {
+		var account model.Account
+		if err := db.DB.NewSelect().Model(&account).Where("id = ?", job.AccountID).Scan(ctx); err != nil {
+			return errors.WithStack(err)
+		}
+		pages, err := batch.GenerateFAQ(db, ctx, &account)
+		job.PagesProcessed = pages
+		return err
+	}
+}
+
+// enqueueFAQGeneration queues a generation job for every account that does not
+// already have one pending.
+func enqueueFAQGeneration(ctx context.Context, db *connector.DB, jobQueue *queue.Queue) error {
+	var ids []string
+	if err := db.DB.NewSelect().Model((*model.Account)(nil)).Column("id").Scan(ctx, &ids); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, id := range ids {
+		if _, err := jobQueue.EnqueueUnique(ctx, model.JobTypeGenerateFAQ, id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const jobListLimit = 50
+
+func newListJobsHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := db.DB.NewSelect().Model((*model.Job)(nil)).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Order("id DESC").
+			Limit(jobListLimit)
+		if jobType := r.URL.Query().Get("type"); jobType != "" {
+			query = query.Where("type = ?", jobType)
+		}
+		if state := r.URL.Query().Get("state"); state != "" {
+			query = query.Where("state = ?", state)
+		}
+		jobs := []*model.Job{}
+		if err := query.Scan(r.Context(), &jobs); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(jobs)
+	})
+}
+
+func newGetJobHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jobID, err := strconv.ParseInt(r.PathValue("jobID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Not Found Job", http.StatusNotFound)
+			return
+		}
+		var job model.Job
+		if err := db.DB.NewSelect().Model(&job).Where("id = ?", jobID).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+			log.Println("Not Found Job: ", err)
+			http.Error(w, "Not Found Job: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(job)
+	})
+}
@@ -2,36 +2,45 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
+	"net"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/rs/cors"
+	"github.com/uptrace/bun"
 
 	"firebase.google.com/go/auth"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockagentruntime/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 
-	"github.com/yamato0211/tsumaziro-faq-server/batch"
 	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/apikey"
 	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
 	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/domainverify"
 	"github.com/yamato0211/tsumaziro-faq-server/pkg/firebase"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/guardrail"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/handoff"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/queue"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/storage"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/webhook"
 )
 
 const (
-	BucketName   = "ottottottotto"
-	htmlFileName = "index.html"
+	blockedAnswer = "申し訳ありませんが、その内容にはお答えできません。"
+
+	apiKeyTouchInterval = time.Minute
 )
 
 type ClaudeRequest struct {
@@ -45,12 +54,18 @@ type ClaudeResponse struct {
 }
 
 type BedrockRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	SessionID string `json:"session_id"`
 }
 
 type BedrockResponse struct {
-	Completion string `json:"completion"`
+	SessionID  string               `json:"session_id,omitempty"`
+	Completion string               `json:"completion"`
+	Blocked    bool                 `json:"blocked,omitempty"`
+	Violation  *guardrail.Violation `json:"violation,omitempty"`
+	Handoff    bool                 `json:"handoff,omitempty"`
+	TicketID   string               `json:"ticket_id,omitempty"`
 }
 
 type GetTitleRequest struct {
@@ -64,10 +79,16 @@ type ScrapBoxResponse struct {
 type CreateAccountRequest struct {
 	SubDomain  string `json:"sub_domain"`
 	Name       string `json:"name"`
-	Email      string `json:"email"`
-	FirebaseID string `json:"firebase_id"`
 	ProjectID  string `json:"project_id"`
 	URL        string `json:"url"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// CreateAccountResponse carries the secret webhooks of the account are signed
+// with. It is only shown here.
+type CreateAccountResponse struct {
+	*model.Account
+	WebhookSecret string `json:"webhook_secret"`
 }
 
 type CrawlData struct {
@@ -91,28 +112,165 @@ func NewAuthMiddelware(fc *auth.Client) func(http.HandlerFunc) http.HandlerFunc
 				return
 			}
 			ctx := context.WithValue(r.Context(), "user_id", token.UID)
+			if email, ok := token.Claims["email"].(string); ok {
+				ctx = context.WithValue(ctx, "email", email)
+			}
+			emailVerified, _ := token.Claims["email_verified"].(bool)
+			ctx = context.WithValue(ctx, "email_verified", emailVerified)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func NewSubDomainMiddelware() func(http.HandlerFunc) http.HandlerFunc {
+// NewRoleMiddelware only lets members of the tenant account holding at least
+// role through and stores their role in the context. It must run after
+// NewAuthMiddelware and NewTenantMiddelware.
+func NewRoleMiddelware(db *connector.DB, role string) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			host := os.Getenv("API_HOST")
-			if host == r.Host {
-				next.ServeHTTP(w, r)
+			firebaseID, ok := r.Context().Value("user_id").(string)
+			if !ok || firebaseID == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
-			splits := strings.Split(r.Host, ".")
-			subDomain := splits[0]
-			// log.Println("subDomain: ", subDomain)
-			ctx := context.WithValue(r.Context(), "sub_domain", subDomain)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			var member model.Member
+			err := db.DB.NewSelect().Model(&member).
+				Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+				Where("firebase_id = ?", firebaseID).
+				Scan(r.Context())
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && !model.RoleAtLeast(member.Role, role)) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "role", member.Role)))
 		})
 	}
 }
 
+// NewAPIKeyMiddelware authenticates requests that carry an API key of the
+// tenant account granting scope. Requests without a key are handed to
+// fallback, or let through as they are when fallback is nil. It must run after
+// NewTenantMiddelware.
+func NewAPIKeyMiddelware(db *connector.DB, scope string, fallback func(http.HandlerFunc) http.HandlerFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		withoutKey := next
+		if fallback != nil {
+			withoutKey = fallback(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(apikey.Header)
+			if key == "" {
+				withoutKey.ServeHTTP(w, r)
+				return
+			}
+			prefix, ok := apikey.ParsePrefix(key)
+			if !ok {
+				http.Error(w, "Unauthorized Error: invalid API key", http.StatusUnauthorized)
+				return
+			}
+			var apiKey model.APIKey
+			err := db.DB.NewSelect().Model(&apiKey).
+				Where("prefix = ?", prefix).
+				Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+				Where("revoked_at IS NULL").
+				Scan(r.Context())
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err != nil || !apikey.Match(key, apiKey.Hash) {
+				log.Println("invalid API key: ", prefix)
+				http.Error(w, "Unauthorized Error: invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if !apiKey.HasScope(scope) {
+				http.Error(w, "Forbidden: API key lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			// last_used_at is written at most once per apiKeyTouchInterval so that
+			// busy keys do not cost a write on every request.
+			now := time.Now()
+			if _, err := db.DB.NewUpdate().Model((*model.APIKey)(nil)).
+				Set("last_used_at = ?", now).
+				Where("id = ?", apiKey.ID).
+				Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-apiKeyTouchInterval)).
+				Exec(r.Context()); err != nil {
+				log.Println("Error: touch API key: ", err)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "api_key_id", apiKey.ID)))
+		})
+	}
+}
+
+// NewTenantMiddelware loads the account named by the {id} path value and
+// stores it as the request's tenant. Requests that arrived on a tenant host
+// must name that host's account.
+func NewTenantMiddelware(db *connector.DB) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.PathValue("id")
+			t := &tenant.Tenant{ID: id, Source: tenant.SourcePath}
+			if host := tenant.FromContext(r.Context()); host != nil {
+				if !strings.EqualFold(host.ID, id) {
+					http.Error(w, "Not Found Sub Domain", http.StatusNotFound)
+					return
+				}
+				t.Source, t.Host = host.Source, host.Host
+			}
+			if id == "" {
+				log.Println("Not Found Sub Domain")
+				http.Error(w, "Not Found Sub Domain", http.StatusNotFound)
+				return
+			}
+			var account model.Account
+			if err := db.DB.NewSelect().Model(&account).Where("id = ?", id).Scan(r.Context()); err != nil {
+				log.Println("Not Found Sub Domain User: ", err)
+				http.Error(w, "Not Found Sub Domain User: "+err.Error(), http.StatusNotFound)
+				return
+			}
+			t.ID = account.ID
+			t.Account = &account
+			next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), t)))
+		})
+	}
+}
+
+// newHostRouter serves tenant hosts, such as acme.example.com/faq or a custom
+// domain, by rewriting the path to the /{id}/faq form the routes are
+// registered under. Every path on a tenant host is rewritten, even one that
+// happens to start with the tenant ID; the /{id}/faq form itself is only
+// served on the API host.
+func newHostRouter(resolver *tenant.Resolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := resolver.ResolveHost(r.Context(), r.Host)
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if t == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u := *r.URL
+		prefix := "/" + t.ID
+		u.Path = prefix + u.Path
+		if u.RawPath != "" {
+			u.RawPath = prefix + u.RawPath
+		}
+		r = r.WithContext(tenant.NewContext(r.Context(), t))
+		r.URL = &u
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
 	region := flag.String("region", "us-east-1", "The AWS region")
 	flag.Parse()
@@ -127,7 +285,6 @@ func main() {
 	}
 
 	client := bedrockagentruntime.NewFromConfig(sdkConfig)
-	s3Client := s3.NewFromConfig(sdkConfig)
 
 	mux := http.NewServeMux()
 
@@ -140,28 +297,68 @@ func main() {
 		log.Fatal(err)
 	}
 
-	subDomainMiddleware := NewSubDomainMiddelware()
+	guardrailCfg := cfg.NewGuardrailConfig()
+	crawlerCfg := cfg.NewCrawlerConfig()
+	tenantCfg := cfg.NewTenantConfig()
+	memberCfg := cfg.NewMemberConfig()
+	store, err := storage.NewBlobStore(cfg.NewStorageConfig(), sdkConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifier := webhook.NewClient(cfg.NewWebhookConfig())
+	tenantResolver := tenant.NewResolver(tenantCfg, db)
+
+	tenantMiddleware := NewTenantMiddelware(db)
+	memberMiddleware := func(role string) func(http.HandlerFunc) http.HandlerFunc {
+		roleMiddleware := NewRoleMiddelware(db, role)
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return NewAuthMiddelware(fc)(roleMiddleware(next))
+		}
+	}
+	// Admin routes take either a member's Firebase ID token or an API key
+	// with scope; API keys are never accepted for managing members or keys.
+	adminMiddleware := func(role, scope string) func(http.HandlerFunc) http.HandlerFunc {
+		apiKeyMiddleware := NewAPIKeyMiddelware(db, scope, memberMiddleware(role))
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return tenantMiddleware(apiKeyMiddleware(next))
+		}
+	}
+	userMiddleware := func(role string) func(http.HandlerFunc) http.HandlerFunc {
+		roleMiddleware := memberMiddleware(role)
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return tenantMiddleware(roleMiddleware(next))
+		}
+	}
+	viewerMiddleware := adminMiddleware(model.MemberRoleViewer, model.APIKeyScopeRead)
+	editorMiddleware := adminMiddleware(model.MemberRoleEditor, model.APIKeyScopeAdmin)
+	ownerMiddleware := userMiddleware(model.MemberRoleOwner)
+	readKeyMiddleware := NewAPIKeyMiddelware(db, model.APIKeyScopeRead, nil)
+	chatKeyMiddleware := NewAPIKeyMiddelware(db, model.APIKeyScopeChat, nil)
+
+	jobQueue := queue.New(db, cfg.NewQueueConfig())
+	pool := queue.NewPool(jobQueue)
+	pool.Handle(model.JobTypeCrawl, newCrawlJobHandler(db, store, crawlerCfg))
+	pool.Handle(model.JobTypeGenerateFAQ, newGenerateFAQJobHandler(db))
 
-	ticker := time.NewTicker(5 * time.Minute)
-	done := make(chan bool)
-	crawlData := make(chan CrawlData)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	defer func() {
-		done <- true
-	}()
+	go pool.Run(ctx)
+
+	ticker := time.NewTicker(5 * time.Minute)
+	scheduleTicker := time.NewTicker(time.Minute)
 
 	go func(db *connector.DB) {
 		for {
 			select {
-			case <-done:
+			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				if err := batch.BatchGenerateFAQ(db, context.Background()); err != nil {
+				if err := enqueueFAQGeneration(ctx, db, jobQueue); err != nil {
 					log.Println("Error: ", err)
 				}
-			case data := <-crawlData:
-				objectKey := data.SubDomain + "/" + htmlFileName
-				if err := batch.CrawlKnowledge(data.URL, BucketName, objectKey, s3Client); err != nil {
+			case <-scheduleTicker.C:
+				if err := runScheduledCrawls(ctx, db, jobQueue); err != nil {
 					log.Println("Error: ", err)
 				}
 			}
@@ -169,54 +366,34 @@ func main() {
 	}(db)
 
 	faqHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// subDomain, ok := r.Context().Value("sub_domain").(string)
-		subDomain := r.PathValue("id")
-		log.Println("subDomain: ", subDomain)
-		// if !ok {
-		// 	log.Println("Internal server error: sub_domain type is not string")
-		// 	http.Error(w, "Internal server error: sub_domain type is not string", http.StatusInternalServerError)
-		// 	return
-		// }
-		if subDomain == "" {
-			log.Println("Not Found Sub Domain")
-			http.Error(w, "Not Found Sub Domain", http.StatusNotFound)
+		account := tenant.FromContext(r.Context()).Account
+		faqs := []*model.FAQ{}
+		if err := db.DB.NewSelect().Model(&faqs).
+			Where("account_id = ?", account.ID).
+			Where("status = ?", model.FAQStatusApproved).
+			Order("position", "id").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		var account model.Account
-		if err := db.DB.NewSelect().Model((*model.Account)(nil)).Where("id = ?", subDomain).Scan(r.Context(), &account); err != nil {
-			log.Println("Not Found Sub Domain User: ", err)
-			http.Error(w, "Not Found Sub Domain User: "+err.Error(), http.StatusNotFound)
-			return
+		res := faqs
+		if category := r.URL.Query().Get("category"); category != "" {
+			res = []*model.FAQ{}
+			for _, faq := range faqs {
+				if hasTag(faq, category) {
+					res = append(res, faq)
+				}
+			}
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		if _, err = w.Write(account.Faqs); err != nil {
-			log.Println("Internal server error: ", err)
-			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+		json.NewEncoder(w).Encode(res)
 	})
 
 	getTitleHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// subDomain, ok := r.Context().Value("sub_domain").(string)
-		subDomain := r.PathValue("id")
-		// if !ok {
-		// 	log.Println("Internal server error: sub_domain type is not string")
-		// 	http.Error(w, "Internal server error: sub_domain type is not string", http.StatusInternalServerError)
-		// 	return
-		// }
-		if subDomain == "" {
-			log.Println("Not Found Sub Domain")
-			http.Error(w, "Not Found Sub Domain", http.StatusNotFound)
-			return
-		}
-		var account model.Account
-		if err := db.DB.NewSelect().Model((*model.Account)(nil)).Where("id = ?", subDomain).Scan(r.Context(), &account); err != nil {
-			log.Println("Not Found Sub Domain User: ", err)
-			http.Error(w, "Not Found Sub Domain User: "+err.Error(), http.StatusNotFound)
-			return
-		}
-		fmt.Println("account: ", subDomain)
+		account := tenant.FromContext(r.Context()).Account
+		fmt.Println("account: ", account.ID)
 
 		var req GetTitleRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -248,93 +425,303 @@ func main() {
 	})
 
 	createAccountHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The owner is always the verified caller, never someone named in the body.
+		firebaseID, ok := r.Context().Value("user_id").(string)
+		if !ok || firebaseID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		email, _ := r.Context().Value("email").(string)
+		if email == "" {
+			http.Error(w, "ID token has no email", http.StatusBadRequest)
+			return
+		}
 		var req CreateAccountRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if req.URL != "" && !validCrawlURL(req.URL) {
+			http.Error(w, "Invalid url: must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+		if req.WebhookURL != "" && !validCrawlURL(req.WebhookURL) {
+			http.Error(w, "Invalid webhook_url: must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+		availability, err := checkSubDomain(r.Context(), db, req.SubDomain)
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch availability.Reason {
+		case UnavailableInvalid:
+			http.Error(w, "Invalid sub_domain: "+availability.Message, http.StatusBadRequest)
+			return
+		case UnavailableReserved, UnavailableTaken:
+			http.Error(w, "Unavailable sub_domain: "+availability.Message, http.StatusConflict)
+			return
+		}
+		req.SubDomain = availability.SubDomain
+		exists, err := db.DB.NewSelect().Model((*model.Account)(nil)).Where("firebase_id = ?", firebaseID).Exists(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "An account already exists for this user", http.StatusConflict)
+			return
+		}
+		webhookSecret, err := webhook.GenerateSecret()
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 		account := &model.Account{
-			ID:         req.SubDomain,
-			Name:       req.Name,
-			Email:      req.Email,
-			ProjectID:  req.ProjectID,
-			FirebaseID: req.FirebaseID,
+			ID:            req.SubDomain,
+			Name:          req.Name,
+			Email:         email,
+			ProjectID:     req.ProjectID,
+			URL:           req.URL,
+			FirebaseID:    firebaseID,
+			WebhookURL:    req.WebhookURL,
+			WebhookSecret: webhookSecret,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		owner := &model.Member{
+			AccountID:  account.ID,
+			FirebaseID: firebaseID,
+			Email:      email,
+			Role:       model.MemberRoleOwner,
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
 		}
-		if _, err := db.DB.NewInsert().Model(account).Exec(r.Context()); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		err = db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewInsert().Model(account).Exec(ctx); err != nil {
+				return err
+			}
+			_, err := tx.NewInsert().Model(owner).Exec(ctx)
+			return err
+		})
+		if err != nil {
+			if connector.IsDuplicateEntry(err) {
+				http.Error(w, accountConflictMessage(err), http.StatusConflict)
+				return
+			}
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		crawlData <- CrawlData{
-			SubDomain: req.SubDomain,
-			URL:       req.URL,
+		if req.URL != "" {
+			if _, err := jobQueue.Enqueue(r.Context(), model.JobTypeCrawl, account.ID, CrawlData{SubDomain: req.SubDomain, URL: req.URL}); err != nil {
+				log.Println("Error: enqueue crawl: ", err)
+			}
+		}
+		if _, err := jobQueue.Enqueue(r.Context(), model.JobTypeGenerateFAQ, account.ID, nil); err != nil {
+			log.Println("Error: enqueue faq generation: ", err)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&CreateAccountResponse{Account: account, WebhookSecret: webhookSecret})
 	})
 
 	bedrockHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account := tenant.FromContext(r.Context()).Account
+
 		req := BedrockRequest{}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		prompt := req.Prompt
+		input := guardrail.NewInputPipeline(guardrailCfg, account.BlockedTerms).Run(req.Prompt)
+		for _, note := range input.Notes {
+			log.Println("guardrail input: ", note)
+		}
+		if input.Blocked() {
+			log.Println("guardrail blocked input: ", input.Violation)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(BedrockResponse{Blocked: true, Violation: input.Violation})
+			return
+		}
 
-		userID := fmt.Sprintf("%d", rand.Intn(1000000))
+		prompt := input.Text
 
-		output, err := client.InvokeAgent(context.Background(), &bedrockagentruntime.InvokeAgentInput{
-			InputText:    aws.String(prompt),
-			AgentId:      aws.String("W5PUPQIIS8"),
-			AgentAliasId: aws.String("GLYSWGXVOT"),
-			SessionId:    aws.String(userID),
-		})
+		sessionID, err := resolveSession(r.Context(), db, account.ID, req.SessionID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			log.Println("Not Found Session: ", err)
+			http.Error(w, "Not Found Session: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := saveMessage(r.Context(), db, sessionID, model.RoleUser, prompt); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		text := ""
-		for event := range output.GetStream().Events() {
-			switch v := event.(type) {
-			case *types.ResponseStreamMemberChunk:
-				text += string(v.Value.Bytes)
+		res := BedrockResponse{SessionID: sessionID}
+		reason := ""
+		if handoff.UserRequested(prompt) {
+			res.Completion = handoff.Message
+			reason = handoff.ReasonUserRequested
+		} else {
+			output, err := client.InvokeAgent(context.Background(), &bedrockagentruntime.InvokeAgentInput{
+				InputText:    aws.String(prompt),
+				AgentId:      aws.String("W5PUPQIIS8"),
+				AgentAliasId: aws.String("GLYSWGXVOT"),
+				SessionId:    aws.String(sessionID),
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 
-			case *types.UnknownUnionMember:
-				fmt.Println("unknown tag:", v.Tag)
+			text := ""
+			for event := range output.GetStream().Events() {
+				switch v := event.(type) {
+				case *types.ResponseStreamMemberChunk:
+					text += string(v.Value.Bytes)
 
-			default:
-				fmt.Println("union is nil or unknown type")
+				case *types.UnknownUnionMember:
+					fmt.Println("unknown tag:", v.Tag)
+
+				default:
+					fmt.Println("union is nil or unknown type")
+				}
+			}
+
+			filtered := guardrail.NewOutputPipeline(guardrailCfg, account.BlockedTerms).Run(text)
+			for _, note := range filtered.Notes {
+				log.Println("guardrail output: ", note)
+			}
+			if filtered.Blocked() {
+				log.Println("guardrail blocked output: ", filtered.Violation)
+				res.Completion = blockedAnswer
+				res.Blocked = true
+				res.Violation = filtered.Violation
+			} else {
+				res.Completion = filtered.Text
+				if handoff.LowConfidence(filtered.Text) {
+					reason = handoff.ReasonLowConfidence
+				}
 			}
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+		if err := saveMessage(r.Context(), db, sessionID, model.RoleAssistant, res.Completion); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-		res := BedrockResponse{
-			Completion: text,
+		if reason != "" {
+			ticket, err := openTicket(r.Context(), db, notifier, account, sessionID, reason)
+			if err != nil {
+				log.Println("Internal server error: ", err)
+				http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Handoff = true
+			res.TicketID = ticket.ID
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(res)
 	})
 
-	mux.HandleFunc("GET /{id}/faq", subDomainMiddleware(faqHandler))
+	mux.HandleFunc("GET /{id}/faq", tenantMiddleware(readKeyMiddleware(faqHandler)))
+
+	mux.HandleFunc("GET /{id}/faq/categories", tenantMiddleware(readKeyMiddleware(newFAQCategoriesHandler(db))))
+
+	mux.HandleFunc("POST /{id}/faq", tenantMiddleware(readKeyMiddleware(getTitleHandler)))
 
-	mux.HandleFunc("POST /{id}/faq", subDomainMiddleware(getTitleHandler))
+	mux.HandleFunc("GET /{id}/faq/{faqID}/related", tenantMiddleware(readKeyMiddleware(newRelatedFAQsHandler(db))))
 
 	mux.HandleFunc("POST /account", NewAuthMiddelware(fc)(createAccountHandler))
 
-	mux.HandleFunc("POST /{id}/bedrock", subDomainMiddleware((bedrockHandler)))
+	mux.HandleFunc("GET /account/availability", newSubDomainAvailabilityHandler(db))
+
+	mux.HandleFunc("GET /account", NewAuthMiddelware(fc)(newGetAccountHandler(db)))
+
+	mux.HandleFunc("PATCH /account", NewAuthMiddelware(fc)(newUpdateAccountHandler(db, jobQueue)))
+
+	mux.HandleFunc("DELETE /account", NewAuthMiddelware(fc)(newDeleteAccountHandler(db, store)))
+
+	mux.HandleFunc("POST /account/webhook-secret", NewAuthMiddelware(fc)(newRotateWebhookSecretHandler(db)))
+
+	mux.HandleFunc("POST /{id}/bedrock", tenantMiddleware(chatKeyMiddleware(bedrockHandler)))
+
+	mux.HandleFunc("GET /{id}/tickets/{ticketID}", tenantMiddleware(newTicketHandler(db)))
+
+	mux.HandleFunc("PATCH /{id}/tickets/{ticketID}", editorMiddleware(newUpdateTicketHandler(db)))
+
+	mux.HandleFunc("GET /{id}/crawl/schedule", viewerMiddleware(newGetCrawlScheduleHandler(db)))
+
+	mux.HandleFunc("PUT /{id}/crawl/schedule", editorMiddleware(newPutCrawlScheduleHandler(db, crawlerCfg)))
+
+	mux.HandleFunc("GET /{id}/faqs", viewerMiddleware(newListFAQsHandler(db)))
+
+	mux.HandleFunc("POST /{id}/faqs", editorMiddleware(newCreateFAQHandler(db)))
+
+	mux.HandleFunc("GET /{id}/faqs/{faqID}", viewerMiddleware(newGetFAQHandler(db)))
+
+	mux.HandleFunc("PATCH /{id}/faqs/{faqID}", editorMiddleware(newUpdateFAQHandler(db)))
+
+	mux.HandleFunc("DELETE /{id}/faqs/{faqID}", editorMiddleware(newDeleteFAQHandler(db)))
+
+	mux.HandleFunc("POST /{id}/faqs/{faqID}/approve", editorMiddleware(newSetFAQStatusHandler(db, model.FAQStatusApproved)))
+
+	mux.HandleFunc("POST /{id}/faqs/{faqID}/hide", editorMiddleware(newSetFAQStatusHandler(db, model.FAQStatusHidden)))
+
+	mux.HandleFunc("POST /{id}/faqs/{faqID}/merge", editorMiddleware(newMergeFAQHandler(db)))
+
+	mux.HandleFunc("GET /{id}/jobs", viewerMiddleware(newListJobsHandler(db)))
+
+	mux.HandleFunc("GET /{id}/jobs/{jobID}", viewerMiddleware(newGetJobHandler(db)))
+
+	mux.HandleFunc("POST /{id}/crawl", editorMiddleware(newTriggerCrawlHandler(db, jobQueue)))
+
+	mux.HandleFunc("GET /{id}/domains", viewerMiddleware(newListCustomDomainsHandler(db)))
+
+	mux.HandleFunc("POST /{id}/domains", ownerMiddleware(newCreateCustomDomainHandler(db, tenantCfg)))
+
+	mux.HandleFunc("POST /{id}/domains/{host}/verify", ownerMiddleware(newVerifyCustomDomainHandler(db, domainverify.NewVerifier(net.DefaultResolver), tenantResolver)))
+
+	mux.HandleFunc("DELETE /{id}/domains/{host}", ownerMiddleware(newDeleteCustomDomainHandler(db, tenantResolver)))
+
+	mux.HandleFunc("GET /{id}/members", viewerMiddleware(newListMembersHandler(db)))
+
+	mux.HandleFunc("PATCH /{id}/members/{memberID}", ownerMiddleware(newUpdateMemberHandler(db)))
+
+	mux.HandleFunc("DELETE /{id}/members/{memberID}", userMiddleware(model.MemberRoleViewer)(newDeleteMemberHandler(db)))
+
+	mux.HandleFunc("GET /{id}/invitations", ownerMiddleware(newListInvitationsHandler(db)))
+
+	mux.HandleFunc("POST /{id}/invitations", ownerMiddleware(newCreateInvitationHandler(db, memberCfg, notifier)))
+
+	mux.HandleFunc("DELETE /{id}/invitations/{invitationID}", ownerMiddleware(newDeleteInvitationHandler(db)))
+
+	mux.HandleFunc("GET /{id}/api-keys", ownerMiddleware(newListAPIKeysHandler(db)))
+
+	mux.HandleFunc("POST /{id}/api-keys", ownerMiddleware(newCreateAPIKeyHandler(db)))
+
+	mux.HandleFunc("DELETE /{id}/api-keys/{keyID}", ownerMiddleware(newRevokeAPIKeyHandler(db)))
+
+	mux.HandleFunc("POST /invitations/{token}/accept", NewAuthMiddelware(fc)(newAcceptInvitationHandler(db)))
 
-	mux.HandleFunc("GET /", subDomainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("GET /", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "Hello, world!")
-	})))
+	}))
 
 	c := cors.AllowAll()
-	corsMux := c.Handler(mux)
+	corsMux := c.Handler(newHostRouter(tenantResolver, mux))
 
 	log.Println("listen and serve ... on port 8080")
 	if err := http.ListenAndServe(":8080", corsMux); err != nil {
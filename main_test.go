@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+)
+
+func TestHostRouter(t *testing.T) {
+	resolver := tenant.NewResolver(&cfg.TenantConfig{
+		APIHost:        "api.tsumaziro.example",
+		BaseDomain:     "tsumaziro.example",
+		DomainCacheTTL: time.Minute,
+	}, nil)
+	tests := []struct {
+		host   string
+		path   string
+		want   string
+		tenant string
+	}{
+		{"acme.tsumaziro.example", "/faq", "/acme/faq", "acme"},
+		{"faq.tsumaziro.example", "/faq", "/faq/faq", "faq"},
+		{"acme.tsumaziro.example", "/acme/faq", "/acme/acme/faq", "acme"},
+		{"api.tsumaziro.example", "/acme/faq", "/acme/faq", ""},
+		{"localhost:8080", "/acme/faq", "/acme/faq", ""},
+	}
+	for _, tt := range tests {
+		var gotPath, gotTenant string
+		router := newHostRouter(resolver, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			if t := tenant.FromContext(r.Context()); t != nil {
+				gotTenant = t.ID
+			}
+		}))
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if gotPath != tt.want || gotTenant != tt.tenant {
+			t.Errorf("%s%s routed to %q for tenant %q, want %q for tenant %q", tt.host, tt.path, gotPath, gotTenant, tt.want, tt.tenant)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	cfg "github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/tenant"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/webhook"
+)
+
+const invitationCreatedEvent = "invitation.created"
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// InvitationResponse carries the token only when the invitation is created;
+// it is never listed again.
+type InvitationResponse struct {
+	*model.Invitation
+	Token string `json:"token"`
+}
+
+// findMember loads the member named by the {memberID} path value, scoped to
+// the tenant account.
+func findMember(r *http.Request, db *connector.DB) (*model.Member, error) {
+	memberID, err := strconv.ParseInt(r.PathValue("memberID"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var member model.Member
+	if err := db.DB.NewSelect().Model(&member).Where("id = ?", memberID).Where("account_id = ?", tenant.FromContext(r.Context()).ID).Scan(r.Context()); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func newListMembersHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		members := []*model.Member{}
+		if err := db.DB.NewSelect().Model(&members).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Order("id").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(members)
+	})
+}
+
+// newUpdateMemberHandler changes the role of a member. The user that created
+// the account always stays an owner, so every account keeps one.
+func newUpdateMemberHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		member, err := findMember(r, db)
+		if err != nil {
+			log.Println("Not Found Member: ", err)
+			http.Error(w, "Not Found Member", http.StatusNotFound)
+			return
+		}
+		var req UpdateMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !model.ValidMemberRole(req.Role) {
+			http.Error(w, "Invalid role: must be owner, editor or viewer", http.StatusBadRequest)
+			return
+		}
+		if member.FirebaseID == tenant.FromContext(r.Context()).Account.FirebaseID && req.Role != model.MemberRoleOwner {
+			http.Error(w, "The account creator must stay an owner", http.StatusConflict)
+			return
+		}
+
+		member.Role = req.Role
+		member.UpdatedAt = time.Now()
+		if _, err := db.DB.NewUpdate().Model(member).Column("role", "updated_at").WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(member)
+	})
+}
+
+// newDeleteMemberHandler removes a member. Owners can remove anyone but the
+// account creator; every other member can only leave.
+func newDeleteMemberHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		member, err := findMember(r, db)
+		if err != nil {
+			log.Println("Not Found Member: ", err)
+			http.Error(w, "Not Found Member", http.StatusNotFound)
+			return
+		}
+		role, _ := r.Context().Value("role").(string)
+		firebaseID, _ := r.Context().Value("user_id").(string)
+		if role != model.MemberRoleOwner && member.FirebaseID != firebaseID {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if member.FirebaseID == tenant.FromContext(r.Context()).Account.FirebaseID {
+			http.Error(w, "The account creator cannot be removed", http.StatusConflict)
+			return
+		}
+		if _, err := db.DB.NewDelete().Model(member).WherePK().Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func newListInvitationsHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invitations := []*model.Invitation{}
+		if err := db.DB.NewSelect().Model(&invitations).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Where("accepted_at IS NULL").
+			Order("id DESC").
+			Scan(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(invitations)
+	})
+}
+
+// newCreateInvitationHandler invites an email address to the account. We send
+// no email ourselves: the invitation, token included, is posted to the
+// account's webhook so the customer's own systems can mail it, and is
+// returned to the inviter, who has to deliver it when no webhook is set. The
+// token is what the invitee presents to accept and is never shown again.
+func newCreateInvitationHandler(db *connector.DB, memberCfg *cfg.MemberConfig, notifier *webhook.Client) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateInvitationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Println("Status Bad Request: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		address, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+		if err != nil {
+			http.Error(w, "Invalid email: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !model.ValidMemberRole(req.Role) {
+			http.Error(w, "Invalid role: must be owner, editor or viewer", http.StatusBadRequest)
+			return
+		}
+
+		t := tenant.FromContext(r.Context())
+		email := strings.ToLower(address.Address)
+		member, err := db.DB.NewSelect().Model((*model.Member)(nil)).
+			Where("account_id = ?", t.ID).
+			Where("LOWER(email) = ?", email).
+			Exists(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if member {
+			http.Error(w, "Email already belongs to a member", http.StatusConflict)
+			return
+		}
+
+		invitationToken, err := token.Generate(32)
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		invitedBy, _ := r.Context().Value("user_id").(string)
+		invitation := &model.Invitation{
+			AccountID: t.ID,
+			Email:     email,
+			Role:      req.Role,
+			TokenHash: token.Hash(invitationToken),
+			InvitedBy: invitedBy,
+			ExpiresAt: time.Now().Add(memberCfg.InvitationTTL),
+			CreatedAt: time.Now(),
+		}
+		if _, err := db.DB.NewInsert().Model(invitation).Exec(r.Context()); err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res := &InvitationResponse{Invitation: invitation, Token: invitationToken}
+		if t.Account.WebhookURL != "" {
+			event := webhook.Event{
+				Type:      invitationCreatedEvent,
+				AccountID: t.ID,
+				Data:      res,
+				SentAt:    time.Now(),
+			}
+			go func(url string) {
+				if err := notifier.Send(context.Background(), url, t.Account.WebhookSecret, event); err != nil {
+					log.Println("Error: notify invitation webhook: ", err)
+				}
+			}(t.Account.WebhookURL)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+func newDeleteInvitationHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invitationID, err := strconv.ParseInt(r.PathValue("invitationID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Not Found Invitation", http.StatusNotFound)
+			return
+		}
+		res, err := db.DB.NewDelete().Model((*model.Invitation)(nil)).
+			Where("id = ?", invitationID).
+			Where("account_id = ?", tenant.FromContext(r.Context()).ID).
+			Where("accepted_at IS NULL").
+			Exec(r.Context())
+		if err != nil {
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Not Found Invitation", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// newAcceptInvitationHandler makes the signed-in user a member of the inviting
+// account. The verified email of the user must match the invited address.
+func newAcceptInvitationHandler(db *connector.DB) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		firebaseID, ok := r.Context().Value("user_id").(string)
+		if !ok || firebaseID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		email, _ := r.Context().Value("email").(string)
+		emailVerified, _ := r.Context().Value("email_verified").(bool)
+		if !emailVerified {
+			http.Error(w, "Email of the signed-in user is not verified", http.StatusForbidden)
+			return
+		}
+
+		var invitation model.Invitation
+		if err := db.DB.NewSelect().Model(&invitation).Where("token_hash = ?", token.Hash(r.PathValue("token"))).Scan(r.Context()); err != nil {
+			log.Println("Not Found Invitation: ", err)
+			http.Error(w, "Not Found Invitation", http.StatusNotFound)
+			return
+		}
+		if invitation.AcceptedAt != nil {
+			http.Error(w, "Invitation has already been accepted", http.StatusConflict)
+			return
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			http.Error(w, "Invitation has expired", http.StatusGone)
+			return
+		}
+		if !strings.EqualFold(email, invitation.Email) {
+			http.Error(w, "Invitation was sent to another email", http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		member := &model.Member{
+			AccountID:  invitation.AccountID,
+			FirebaseID: firebaseID,
+			Email:      invitation.Email,
+			Role:       invitation.Role,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		invitation.AcceptedAt = &now
+		err := db.DB.RunInTx(r.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewInsert().Model(member).Exec(ctx); err != nil {
+				return err
+			}
+			res, err := tx.NewUpdate().Model(&invitation).Column("accepted_at").WherePK().Where("accepted_at IS NULL").Exec(ctx)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				return sql.ErrNoRows
+			}
+			return nil
+		})
+		if err != nil {
+			if connector.IsDuplicateEntry(err) {
+				http.Error(w, "Already a member of this account", http.StatusConflict)
+				return
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Invitation has already been accepted", http.StatusConflict)
+				return
+			}
+			log.Println("Internal server error: ", err)
+			http.Error(w, "Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&invitation)
+	})
+}
@@ -10,6 +10,39 @@ import (
 
 func main() {
 	d := db.NewMySQLConnector(config.NewDBConfig())
+	if _, err := d.DB.NewDropTable().Model(&model.APIKey{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.Member{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.Invitation{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.CustomDomain{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.PageLink{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.FAQ{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.Job{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.CrawlSchedule{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.ChatSession{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.ChatMessage{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
+	if _, err := d.DB.NewDropTable().Model(&model.Ticket{}).Exec(context.TODO()); err != nil {
+		panic(err)
+	}
 	if _, err := d.DB.NewDropTable().Model(&model.Account{}).Exec(context.TODO()); err != nil {
 		panic(err)
 	}
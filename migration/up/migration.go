@@ -14,4 +14,40 @@ func main() {
 	if err := model.MigrateModel(d); err != nil {
 		panic(err)
 	}
+	if err := model.MigrateChatSession(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateChatMessage(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateTicket(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateCrawlSchedule(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateJob(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateFAQ(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateLegacyFAQs(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigratePageLink(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateCustomDomain(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateMember(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateInvitation(d); err != nil {
+		panic(err)
+	}
+	if err := model.MigrateAPIKey(d); err != nil {
+		panic(err)
+	}
 }
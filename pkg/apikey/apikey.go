@@ -0,0 +1,59 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+)
+
+const (
+	// Header is the request header API keys are sent in.
+	Header = "X-API-Key"
+	// Prefix starts every key so that leaked keys are easy to recognize.
+	Prefix = "tsz_"
+
+	idLength = 8
+)
+
+// Generate returns a new key of the form tsz_<id>_<secret> together with its
+// public prefix tsz_<id>, which identifies the key without revealing it.
+func Generate() (key, prefix string, err error) {
+	id, err := token.Generate(idLength / 2)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := token.Generate(24)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = Prefix + id
+	return prefix + "_" + secret, prefix, nil
+}
+
+// ParsePrefix returns the public prefix of key, or false when key is not
+// shaped like one of ours.
+func ParsePrefix(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, Prefix)
+	if !ok {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != idLength || secret == "" {
+		return "", false
+	}
+	return Prefix + id, true
+}
+
+// Hash is what is stored instead of the key.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Match reports whether key hashes to hash, in constant time.
+func Match(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,61 @@
+package apikey
+
+import "testing"
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		ok     bool
+	}{
+		{"tsz_0a1b2c3d_secret", "tsz_0a1b2c3d", true},
+		{"tsz_0a1b2c3d_sec_ret", "tsz_0a1b2c3d", true},
+		{"tsz_0a1b2c3d_", "", false},
+		{"tsz_0a1b2c3d", "", false},
+		{"tsz_0a1b2c_secret", "", false},
+		{"tsz_0a1b2c3d4_secret", "", false},
+		{"TSZ_0a1b2c3d_secret", "", false},
+		{"sk_0a1b2c3d_secret", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		prefix, ok := ParsePrefix(tt.key)
+		if prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("ParsePrefix(%q) = %q, %v, want %q, %v", tt.key, prefix, ok, tt.prefix, tt.ok)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	key, prefix, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := ParsePrefix(key); !ok || got != prefix {
+		t.Errorf("ParsePrefix(%q) = %q, %v, want %q, true", key, got, ok, prefix)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	key, _, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := Hash(key)
+	tests := []struct {
+		name string
+		key  string
+		hash string
+		want bool
+	}{
+		{"same key", key, hash, true},
+		{"other key", key + "x", hash, false},
+		{"empty hash", key, "", false},
+		{"key as hash", key, key, false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.key, tt.hash); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
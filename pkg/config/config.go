@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +22,58 @@ type FirebaseConfig struct {
 	FirebaseSecret string
 }
 
+type GuardrailConfig struct {
+	MaxInputLength     int
+	MaskPII            bool
+	BlockPII           bool
+	BlockedTerms       []string
+	OutputBlockedTerms []string
+}
+
+type WebhookConfig struct {
+	Timeout time.Duration
+}
+
+type CrawlerConfig struct {
+	UserAgent           string
+	MaxDepth            int
+	MaxPages            int
+	MaxDocumentSize     int64
+	MaxBodySize         int64
+	MinScheduleInterval time.Duration
+	Timeout             time.Duration
+	Concurrency         int
+	HostInterval        time.Duration
+}
+
+type TenantConfig struct {
+	APIHost        string
+	BaseDomain     string
+	DomainClaimTTL time.Duration
+	// DomainCacheTTL is how long a custom domain lookup, found or not, is
+	// remembered.
+	DomainCacheTTL time.Duration
+}
+
+type MemberConfig struct {
+	InvitationTTL time.Duration
+}
+
+type StorageConfig struct {
+	Backend  string
+	Bucket   string
+	LocalDir string
+}
+
+type QueueConfig struct {
+	Workers       int
+	MaxAttempts   int
+	LeaseDuration time.Duration
+	PollInterval  time.Duration
+	BackoffBase   time.Duration
+	BackoffMax    time.Duration
+}
+
 func NewDBConfig() *DBConfig {
 	godotenv.Load()
 
@@ -42,3 +97,129 @@ func NewFirebaseConfig() *FirebaseConfig {
 
 	return cfg
 }
+
+func NewGuardrailConfig() *GuardrailConfig {
+	godotenv.Load()
+
+	cfg := &GuardrailConfig{
+		MaxInputLength:     getEnvInt("GUARDRAIL_MAX_INPUT_LENGTH", 1000),
+		MaskPII:            getEnvBool("GUARDRAIL_MASK_PII", true),
+		BlockPII:           getEnvBool("GUARDRAIL_BLOCK_PII", false),
+		BlockedTerms:       getEnvList("GUARDRAIL_BLOCKED_TERMS"),
+		OutputBlockedTerms: getEnvList("GUARDRAIL_OUTPUT_BLOCKED_TERMS"),
+	}
+	return cfg
+}
+
+func NewWebhookConfig() *WebhookConfig {
+	godotenv.Load()
+
+	cfg := &WebhookConfig{
+		Timeout: getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+	}
+	return cfg
+}
+
+func NewCrawlerConfig() *CrawlerConfig {
+	godotenv.Load()
+
+	cfg := &CrawlerConfig{
+		UserAgent:           getEnv("CRAWLER_USER_AGENT", "TsumaziroBot/1.0"),
+		MaxDepth:            getEnvInt("CRAWLER_MAX_DEPTH", 3),
+		MaxPages:            getEnvInt("CRAWLER_MAX_PAGES", 200),
+		MaxDocumentSize:     int64(getEnvInt("CRAWLER_MAX_DOCUMENT_SIZE", 20<<20)),
+		MaxBodySize:         int64(getEnvInt("CRAWLER_MAX_BODY_SIZE", 5<<20)),
+		MinScheduleInterval: getEnvDuration("CRAWLER_MIN_SCHEDULE_INTERVAL", time.Hour),
+		Timeout:             getEnvDuration("CRAWLER_TIMEOUT", 30*time.Second),
+		Concurrency:         getEnvInt("CRAWLER_CONCURRENCY", 4),
+		HostInterval:        getEnvDuration("CRAWLER_HOST_INTERVAL", 500*time.Millisecond),
+	}
+	return cfg
+}
+
+func NewStorageConfig() *StorageConfig {
+	godotenv.Load()
+
+	cfg := &StorageConfig{
+		Backend:  getEnv("STORAGE_BACKEND", "s3"),
+		Bucket:   getEnv("KNOWLEDGE_BUCKET", "ottottottotto"),
+		LocalDir: getEnv("STORAGE_LOCAL_DIR", "storage"),
+	}
+	return cfg
+}
+
+func NewQueueConfig() *QueueConfig {
+	godotenv.Load()
+
+	cfg := &QueueConfig{
+		Workers:       getEnvInt("QUEUE_WORKERS", 2),
+		MaxAttempts:   getEnvInt("QUEUE_MAX_ATTEMPTS", 5),
+		LeaseDuration: getEnvDuration("QUEUE_LEASE_DURATION", time.Minute),
+		PollInterval:  getEnvDuration("QUEUE_POLL_INTERVAL", 5*time.Second),
+		BackoffBase:   getEnvDuration("QUEUE_BACKOFF_BASE", 30*time.Second),
+		BackoffMax:    getEnvDuration("QUEUE_BACKOFF_MAX", time.Hour),
+	}
+	return cfg
+}
+
+func NewTenantConfig() *TenantConfig {
+	godotenv.Load()
+
+	cfg := &TenantConfig{
+		APIHost:        getEnv("API_HOST", ""),
+		BaseDomain:     getEnv("TENANT_BASE_DOMAIN", ""),
+		DomainClaimTTL: getEnvDuration("CUSTOM_DOMAIN_CLAIM_TTL", 72*time.Hour),
+		DomainCacheTTL: getEnvDuration("CUSTOM_DOMAIN_CACHE_TTL", time.Minute),
+	}
+	return cfg
+}
+
+func NewMemberConfig() *MemberConfig {
+	godotenv.Load()
+
+	cfg := &MemberConfig{
+		InvitationTTL: getEnvDuration("INVITATION_TTL", 7*24*time.Hour),
+	}
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1- * * * *",
+		"-1 * * * *",
+		"1,,2 * * * *",
+		"@reboot",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	from := time.Date(2024, 1, 31, 10, 7, 30, 0, jst) // Wednesday
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2024, 1, 31, 10, 8, 0, 0, jst)},
+		{"*/15 * * * *", from, time.Date(2024, 1, 31, 10, 15, 0, 0, jst)},
+		{"0 9-18 * * 1-5", from, time.Date(2024, 1, 31, 11, 0, 0, 0, jst)},
+		{"30 3 * * *", from, time.Date(2024, 2, 1, 3, 30, 0, 0, jst)},
+		{"@daily", from, time.Date(2024, 2, 1, 0, 0, 0, 0, jst)},
+		{"@hourly", from, time.Date(2024, 1, 31, 11, 0, 0, 0, jst)},
+		{"@monthly", from, time.Date(2024, 2, 1, 0, 0, 0, 0, jst)},
+		{"0 0 * * 7", from, time.Date(2024, 2, 4, 0, 0, 0, 0, jst)},
+		{"0 0 * * 0", from, time.Date(2024, 2, 4, 0, 0, 0, 0, jst)},
+		{"0 0 29 2 *", from, time.Date(2024, 2, 29, 0, 0, 0, 0, jst)},
+		{"0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, jst), time.Date(2028, 2, 29, 0, 0, 0, 0, jst)},
+		{"0 12 1,15 * *", from, time.Date(2024, 2, 1, 12, 0, 0, 0, jst)},
+		// Either restricted day field may match.
+		{"0 0 13 * 5", from, time.Date(2024, 2, 2, 0, 0, 0, 0, jst)},
+		{"10-20/5 8 * * *", from, time.Date(2024, 2, 1, 8, 10, 0, 0, jst)},
+		{"0 0 31 2 *", from, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
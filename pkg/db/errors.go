@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlErrDupEntry is ER_DUP_ENTRY, returned when a unique key is violated.
+const mysqlErrDupEntry = 1062
+
+// IsDuplicateEntry reports whether err was caused by a unique key violation.
+func IsDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDupEntry
+}
+
+// DuplicateEntryKey returns the name of the unique key that err violated, such
+// as "PRIMARY" or "email". MySQL 8 qualifies the name with the table, which is
+// dropped.
+func DuplicateEntryKey(err error) (string, bool) {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDupEntry {
+		return "", false
+	}
+	// The message reads "Duplicate entry '<value>' for key '<key>'". The value
+	// may itself contain the marker, so the last one is used.
+	i := strings.LastIndex(mysqlErr.Message, "for key '")
+	if i < 0 {
+		return "", false
+	}
+	key := strings.TrimSuffix(mysqlErr.Message[i+len("for key '"):], "'")
+	if j := strings.LastIndex(key, "."); j >= 0 {
+		key = key[j+1:]
+	}
+	return key, true
+}
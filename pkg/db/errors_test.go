@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestDuplicateEntryKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		key  string
+		ok   bool
+	}{
+		{"mysql 8", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'users.email'"}, "email", true},
+		{"mysql 5.7", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'acme' for key 'PRIMARY'"}, "PRIMARY", true},
+		{"wrapped", fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'users.firebase_id'"}), "firebase_id", true},
+		{"value naming another key", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'email' for key 'users.PRIMARY'"}, "PRIMARY", true},
+		{"value containing the marker", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x for key 'email'' for key 'project_id'"}, "project_id", true},
+		{"other mysql error", &mysql.MySQLError{Number: 1054, Message: "Unknown column 'x' in 'field list'"}, "", false},
+		{"not mysql", errors.New("Duplicate entry 'x' for key 'email'"), "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := DuplicateEntryKey(tt.err)
+			if key != tt.key || ok != tt.ok {
+				t.Errorf("DuplicateEntryKey = %q, %v, want %q, %v", key, ok, tt.key, tt.ok)
+			}
+			if got := IsDuplicateEntry(tt.err); got != (tt.err != nil && tt.ok) {
+				t.Errorf("IsDuplicateEntry = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package domainverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	// RecordPrefix is prepended to the custom domain to form the TXT record name.
+	RecordPrefix = "_tsumaziro-verify."
+	// ValuePrefix is prepended to the verification token in the TXT record value.
+	ValuePrefix = "tsumaziro-verify="
+)
+
+var (
+	ErrInvalidHost = errors.New("invalid host")
+	ErrNotVerified = errors.New("verification TXT record not found")
+)
+
+var labelRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TXTResolver looks up DNS TXT records. *net.Resolver satisfies it; tests can
+// pass a fake.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type Verifier struct {
+	resolver TXTResolver
+}
+
+func NewVerifier(resolver TXTResolver) *Verifier {
+	return &Verifier{resolver: resolver}
+}
+
+// ValidateHost reports whether host is a fully qualified lowercase host name
+// with at least two labels.
+func ValidateHost(host string) error {
+	if len(host) > 253 {
+		return ErrInvalidHost
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return ErrInvalidHost
+	}
+	for _, label := range labels {
+		if !labelRe.MatchString(label) {
+			return ErrInvalidHost
+		}
+	}
+	return nil
+}
+
+func RecordName(host string) string {
+	return RecordPrefix + host
+}
+
+func RecordValue(token string) string {
+	return ValuePrefix + token
+}
+
+// Verify checks that the TXT record for host carries token. A missing record
+// is reported as ErrNotVerified; other lookup failures are returned as is.
+func (v *Verifier) Verify(ctx context.Context, host, token string) error {
+	records, err := v.resolver.LookupTXT(ctx, RecordName(host))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrNotVerified
+		}
+		return err
+	}
+	want := RecordValue(token)
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return nil
+		}
+	}
+	return ErrNotVerified
+}
@@ -0,0 +1,87 @@
+package domainverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateHost(t *testing.T) {
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"help.example.com", true},
+		{"example.co.jp", true},
+		{"xn--eckwd4c7c.jp", true},
+		{"a-b.example.com", true},
+		{"example", false},
+		{"", false},
+		{"Help.example.com", false},
+		{"help.example.com.", false},
+		{".example.com", false},
+		{"help..example.com", false},
+		{"-help.example.com", false},
+		{"help-.example.com", false},
+		{"help_desk.example.com", false},
+		{"help.example.com:8080", false},
+		{"ヘルプ.example.com", false},
+		{strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a.", 127) + "com", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateHost(tt.host); (err == nil) != tt.ok {
+			t.Errorf("ValidateHost(%q) = %v, want ok %v", tt.host, err, tt.ok)
+		}
+	}
+}
+
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestVerify(t *testing.T) {
+	timeout := &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		want     error
+	}{
+		{"record present", &fakeResolver{records: map[string][]string{
+			"_tsumaziro-verify.help.example.com": {"v=spf1 -all", " tsumaziro-verify=secret "},
+		}}, nil},
+		{"other token", &fakeResolver{records: map[string][]string{
+			"_tsumaziro-verify.help.example.com": {"tsumaziro-verify=other"},
+		}}, ErrNotVerified},
+		{"token without prefix", &fakeResolver{records: map[string][]string{
+			"_tsumaziro-verify.help.example.com": {"secret"},
+		}}, ErrNotVerified},
+		{"record on the host itself", &fakeResolver{records: map[string][]string{
+			"help.example.com": {"tsumaziro-verify=secret"},
+		}}, ErrNotVerified},
+		{"no record", &fakeResolver{}, ErrNotVerified},
+		{"lookup failure", &fakeResolver{err: timeout}, timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewVerifier(tt.resolver).Verify(context.Background(), "help.example.com", "secret")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
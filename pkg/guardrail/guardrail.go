@@ -0,0 +1,141 @@
+package guardrail
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+)
+
+// Violation describes why a stage blocked a message.
+type Violation struct {
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("guardrail %s: %s", v.Stage, v.Reason)
+}
+
+// Stage is a single step of a guardrail pipeline.
+// Apply returns the (possibly rewritten) text, notes describing any rewrite,
+// and a non-nil Violation when the text must be blocked.
+type Stage interface {
+	Name() string
+	Apply(text string) (string, []string, *Violation)
+}
+
+type Result struct {
+	Text      string
+	Notes     []string
+	Violation *Violation
+}
+
+func (r *Result) Blocked() bool {
+	return r.Violation != nil
+}
+
+type Pipeline struct {
+	stages []Stage
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Run applies every stage in order and stops at the first violation.
+func (p *Pipeline) Run(text string) *Result {
+	res := &Result{Text: text}
+	for _, stage := range p.stages {
+		out, notes, v := stage.Apply(res.Text)
+		for _, note := range notes {
+			res.Notes = append(res.Notes, stage.Name()+": "+note)
+		}
+		if v != nil {
+			res.Violation = v
+			return res
+		}
+		res.Text = out
+	}
+	return res
+}
+
+type maxLength struct {
+	max int
+}
+
+// MaxLength blocks text longer than max characters. A non-positive max disables the check.
+func MaxLength(max int) Stage {
+	return &maxLength{max: max}
+}
+
+func (s *maxLength) Name() string { return "max_length" }
+
+func (s *maxLength) Apply(text string) (string, []string, *Violation) {
+	if s.max <= 0 {
+		return text, nil, nil
+	}
+	if n := utf8.RuneCountInString(text); n > s.max {
+		return text, nil, &Violation{
+			Stage:  s.Name(),
+			Reason: fmt.Sprintf("input is %d characters, limit is %d", n, s.max),
+		}
+	}
+	return text, nil, nil
+}
+
+type blockedTerms struct {
+	name  string
+	terms []string
+}
+
+// BlockedTerms blocks text containing any of terms, compared case-insensitively.
+func BlockedTerms(name string, terms []string) Stage {
+	var normalized []string
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	return &blockedTerms{name: name, terms: normalized}
+}
+
+func (s *blockedTerms) Name() string { return s.name }
+
+func (s *blockedTerms) Apply(text string) (string, []string, *Violation) {
+	lower := strings.ToLower(text)
+	for _, t := range s.terms {
+		if strings.Contains(lower, t) {
+			return text, nil, &Violation{
+				Stage:  s.name,
+				Reason: fmt.Sprintf("contains blocked term %q", t),
+			}
+		}
+	}
+	return text, nil, nil
+}
+
+// NewInputPipeline builds the pipeline applied to user messages before they reach the agent.
+func NewInputPipeline(cfg *config.GuardrailConfig, accountTerms []string) *Pipeline {
+	stages := []Stage{MaxLength(cfg.MaxInputLength)}
+	if cfg.BlockPII || cfg.MaskPII {
+		stages = append(stages, PII(cfg.BlockPII))
+	}
+	terms := append(append([]string{}, cfg.BlockedTerms...), accountTerms...)
+	stages = append(stages, BlockedTerms("blocked_terms", terms))
+	return NewPipeline(stages...)
+}
+
+// NewOutputPipeline builds the pipeline applied to agent answers before they are returned.
+// PII in answers is always masked rather than blocked.
+func NewOutputPipeline(cfg *config.GuardrailConfig, accountTerms []string) *Pipeline {
+	var stages []Stage
+	if cfg.BlockPII || cfg.MaskPII {
+		stages = append(stages, PII(false))
+	}
+	terms := append(append([]string{}, cfg.OutputBlockedTerms...), accountTerms...)
+	stages = append(stages, BlockedTerms("output_filter", terms))
+	return NewPipeline(stages...)
+}
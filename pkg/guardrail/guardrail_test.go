@@ -0,0 +1,36 @@
+package guardrail
+
+import "testing"
+
+func TestPipeline(t *testing.T) {
+	pipeline := NewPipeline(MaxLength(10), PII(false), BlockedTerms("blocked_terms", []string{" Casino ", ""}))
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		blocked string
+	}{
+		{"passes", "hello", "hello", ""},
+		{"counts characters, not bytes", "こんにちは世界", "こんにちは世界", ""},
+		{"too long", "hello world!", "", "max_length"},
+		{"masks", "a@b.jp", "[EMAIL]", ""},
+		{"blocked term is case-insensitive", "CASINO", "", "blocked_terms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := pipeline.Run(tt.in)
+			if tt.blocked != "" {
+				if !res.Blocked() || res.Violation.Stage != tt.blocked {
+					t.Fatalf("Run(%q) violation = %v, want stage %q", tt.in, res.Violation, tt.blocked)
+				}
+				return
+			}
+			if res.Blocked() {
+				t.Fatalf("Run(%q) blocked: %v", tt.in, res.Violation)
+			}
+			if res.Text != tt.want {
+				t.Errorf("Run(%q) = %q, want %q", tt.in, res.Text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[ \-]?)?\(?0?\d{1,4}\)?[ \-]?\d{1,4}[ \-]?\d{3,4}\b`)
+)
+
+type piiKind struct {
+	name    string
+	mask    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// Credit cards are checked before phone numbers so that card digits are not
+// partially masked as a phone number.
+var piiKinds = []piiKind{
+	{name: "email", mask: "[EMAIL]", pattern: emailPattern},
+	{name: "credit_card", mask: "[CREDIT_CARD]", pattern: cardPattern, valid: luhn},
+	{name: "phone", mask: "[PHONE]", pattern: phonePattern, valid: phoneDigits},
+}
+
+type pii struct {
+	block bool
+}
+
+// PII masks email addresses, phone numbers and credit card numbers.
+// When block is true the message is rejected instead of masked.
+func PII(block bool) Stage {
+	return &pii{block: block}
+}
+
+func (s *pii) Name() string { return "pii" }
+
+func (s *pii) Apply(text string) (string, []string, *Violation) {
+	var notes []string
+	for _, kind := range piiKinds {
+		count := 0
+		text = kind.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if kind.valid != nil && !kind.valid(match) {
+				return match
+			}
+			count++
+			return kind.mask
+		})
+		if count == 0 {
+			continue
+		}
+		if s.block {
+			return text, nil, &Violation{
+				Stage:  s.Name(),
+				Reason: fmt.Sprintf("contains %s", kind.name),
+			}
+		}
+		notes = append(notes, fmt.Sprintf("masked %d %s", count, kind.name))
+	}
+	return text, notes, nil
+}
+
+func digits(s string) []int {
+	var ds []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			ds = append(ds, int(r-'0'))
+		}
+	}
+	return ds
+}
+
+func luhn(s string) bool {
+	ds := digits(s)
+	if len(ds) < 13 || len(ds) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(ds) - 1; i >= 0; i-- {
+		d := ds[i]
+		if (len(ds)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func phoneDigits(s string) bool {
+	n := len(digits(s))
+	return n >= 10 && n <= 15
+}
@@ -0,0 +1,72 @@
+package guardrail
+
+import "testing"
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"5500005555555559", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"411111111111", false},         // too short
+		{"41111111111111111111", false}, // too long
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.in); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPIIMask(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "mail me at taro.yamada+faq@example.co.jp please", "mail me at [EMAIL] please"},
+		{"card", "card 4111 1111 1111 1111 ok", "card [CREDIT_CARD] ok"},
+		{"mobile phone", "call 090-1234-5678", "call [PHONE]"},
+		{"international phone", "call +81 90 1234 5678", "call [PHONE]"},
+		{"short number is kept", "room 1234", "room 1234"},
+		{"nothing", "how do I reset my password?", "how do I reset my password?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, v := PII(false).Apply(tt.in)
+			if v != nil {
+				t.Fatalf("Apply(%q) blocked: %v", tt.in, v)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPIIBlock(t *testing.T) {
+	tests := []struct {
+		in     string
+		reason string
+	}{
+		{"a@example.com", "contains email"},
+		{"4111111111111111", "contains credit_card"},
+		{"03-1234-5678", "contains phone"},
+		{"no personal data", ""},
+	}
+	for _, tt := range tests {
+		_, _, v := PII(true).Apply(tt.in)
+		switch {
+		case tt.reason == "" && v != nil:
+			t.Errorf("Apply(%q) blocked: %v", tt.in, v)
+		case tt.reason != "" && (v == nil || v.Reason != tt.reason):
+			t.Errorf("Apply(%q) = %v, want reason %q", tt.in, v, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handoff
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	ReasonUserRequested = "user_requested"
+	ReasonLowConfidence = "low_confidence"
+)
+
+// Message is returned to the end user instead of an agent answer once a handoff starts.
+const Message = "担当者におつなぎします。確認のうえご連絡いたしますので、しばらくお待ちください。"
+
+// humanRequestPatterns match a request to be put through to a person, not
+// just a mention of one, so questions such as "スタッフの営業時間は？" still go
+// to the agent.
+var humanRequestPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(人間|担当者|担当の方|オペレーター?|スタッフ|有人)(と|に|へ|の方と|の方に)(話|はな|代わ|かわ|替わ|つな|繋|相談|対応して)`),
+	regexp.MustCompile(`(^|[、。\s])人と(話|はな)`),
+	regexp.MustCompile(`(電話|有人)(で|に)(対応して|相談したい|話したい)`),
+	regexp.MustCompile(`\b(talk|speak|chat)\s+(to|with)\s+(a\s+|an\s+|the\s+|your\s+)?(human|person|real person|someone|somebody|operator|agent|representative|support staff|staff member)\b`),
+	regexp.MustCompile(`\b(connect|transfer|put)\s+me\s+(to|through to|with)\b`),
+	regexp.MustCompile(`\b(human|live)\s+(agent|support|operator)\s+please\b|\bagent\s+please\b`),
+}
+
+var lowConfidencePhrases = []string{
+	"わかりません",
+	"分かりません",
+	"わかりかねます",
+	"分かりかねます",
+	"お答えできません",
+	"回答できません",
+	"情報が見つかりません",
+	"見つかりませんでした",
+	"i don't know",
+	"i do not know",
+	"i'm not sure",
+	"i am not sure",
+	"unable to find",
+	"cannot answer",
+	"sorry, i",
+}
+
+// UserRequested reports whether the user explicitly asked to talk to a person.
+func UserRequested(prompt string) bool {
+	lower := strings.ToLower(prompt)
+	for _, p := range humanRequestPatterns {
+		if p.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// LowConfidence reports whether the agent answer looks like it could not answer the question.
+func LowConfidence(answer string) bool {
+	if strings.TrimSpace(answer) == "" {
+		return true
+	}
+	return containsAny(answer, lowConfidencePhrases)
+}
+
+func containsAny(text string, phrases []string) bool {
+	lower := strings.ToLower(text)
+	for _, p := range phrases {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package handoff
+
+import "testing"
+
+func TestUserRequested(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   bool
+	}{
+		{"担当者と話したいです", true},
+		{"オペレーターに代わってください", true},
+		{"人間につないでもらえますか", true},
+		{"スタッフに相談したい", true},
+		{"人と話したい", true},
+		{"すみません、人と話せますか", true},
+		{"電話で対応してほしい", true},
+		{"I want to talk to a human", true},
+		{"Can I speak with a real person?", true},
+		{"please connect me to support", true},
+		{"Human agent please", true},
+		// Mentions of people or phones that are not asking for a person.
+		{"友人に話したらこのサービスを勧められました", false},
+		{"担当者の連絡先はどこに書いてありますか", false},
+		{"電話番号を変更したい", false},
+		{"電話で問い合わせできますか", false},
+		{"How does a human review work?", false},
+		{"What is a user agent?", false},
+		{"パスワードを忘れました", false},
+	}
+	for _, tt := range tests {
+		if got := UserRequested(tt.prompt); got != tt.want {
+			t.Errorf("UserRequested(%q) = %v, want %v", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func TestLowConfidence(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"", true},
+		{"   ", true},
+		{"申し訳ありませんが、わかりません。", true},
+		{"I'm not sure about that.", true},
+		{"Sorry, I couldn't find it.", true},
+		{"設定画面からパスワードを変更できます。", false},
+		{"You can reset it from the settings page.", false},
+	}
+	for _, tt := range tests {
+		if got := LowConfidence(tt.answer); got != tt.want {
+			t.Errorf("LowConfidence(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
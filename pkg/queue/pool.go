@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+)
+
+// HandlerFunc processes one leased job. Returning an error schedules a retry.
+type HandlerFunc func(ctx context.Context, job *model.Job) error
+
+// Pool runs a fixed number of workers that lease jobs from a Queue and
+// dispatch them to the handler registered for the job type.
+type Pool struct {
+	queue    *Queue
+	handlers map[string]HandlerFunc
+}
+
+func NewPool(queue *Queue) *Pool {
+	return &Pool{queue: queue, handlers: make(map[string]HandlerFunc)}
+}
+
+func (p *Pool) Handle(jobType string, handler HandlerFunc) {
+	p.handlers[jobType] = handler
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every worker has
+// finished its current job.
+func (p *Pool) Run(ctx context.Context) {
+	var jobTypes []string
+	for jobType := range p.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+	host, _ := os.Hostname()
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.queue.cfg.Workers; i++ {
+		wg.Add(1)
+		go func(workerID string) {
+			defer wg.Done()
+			p.work(ctx, workerID, jobTypes)
+		}(fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i))
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context, workerID string, jobTypes []string) {
+	for {
+		job, err := p.queue.Lease(ctx, workerID, jobTypes)
+		if err != nil && ctx.Err() == nil {
+			log.Println("Error: lease job: ", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.queue.cfg.PollInterval):
+			}
+			continue
+		}
+		p.process(ctx, job)
+	}
+}
+
+func (p *Pool) process(ctx context.Context, job *model.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Keep the lease alive while the handler runs; losing it means another
+	// worker may already have taken the job over.
+	go func() {
+		ticker := time.NewTicker(p.queue.cfg.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := p.queue.Heartbeat(jobCtx, job); err != nil {
+					log.Println("Error: heartbeat: ", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := p.run(jobCtx, job)
+	// Record the outcome even when the pool is shutting down.
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		log.Printf("job %d (%s, %s) failed on attempt %d: %+v\n", job.ID, job.Type, job.AccountID, job.Attempts, err)
+		if err := p.queue.Fail(ctx, job, err); err != nil {
+			log.Println("Error: ", err)
+		}
+		return
+	}
+	if err := p.queue.Complete(ctx, job); err != nil {
+		log.Println("Error: ", err)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job *model.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+	handler, ok := p.handlers[job.Type]
+	if !ok {
+		return errors.Errorf("no handler for job type %q", job.Type)
+	}
+	return handler(ctx, job)
+}
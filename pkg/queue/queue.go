@@ -0,0 +1,230 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+)
+
+// Queue is a MySQL-backed job queue. Jobs are leased for a limited time so that
+// a job whose worker died is picked up again once its lease expires.
+type Queue struct {
+	db  *db.DB
+	cfg *config.QueueConfig
+}
+
+func New(db *db.DB, cfg *config.QueueConfig) *Queue {
+	return &Queue{db: db, cfg: cfg}
+}
+
+// Enqueue adds a job that is ready to run immediately.
+func (q *Queue) Enqueue(ctx context.Context, jobType, accountID string, payload any) (*model.Job, error) {
+	return q.enqueue(ctx, jobType, accountID, payload, nil)
+}
+
+// EnqueueUnique enqueues a job unless one of the same type is already queued or
+// running for the account, in which case the existing job is returned. The
+// unique dedupe key makes concurrent callers agree on a single job.
+func (q *Queue) EnqueueUnique(ctx context.Context, jobType, accountID string, payload any) (*model.Job, error) {
+	key := jobType + ":" + accountID
+	for {
+		job, err := q.enqueue(ctx, jobType, accountID, payload, &key)
+		if err == nil {
+			return job, nil
+		}
+		if !db.IsDuplicateEntry(err) {
+			return nil, err
+		}
+		var existing model.Job
+		err = q.db.DB.NewSelect().Model(&existing).Where("dedupe_key = ?", key).Scan(ctx)
+		if err == nil {
+			return &existing, nil
+		}
+		// The existing job finished in between; try again.
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.WithStack(err)
+		}
+	}
+}
+
+func (q *Queue) enqueue(ctx context.Context, jobType, accountID string, payload any, dedupeKey *string) (*model.Job, error) {
+	marshaled, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	now := time.Now()
+	job := &model.Job{
+		Type:        jobType,
+		AccountID:   accountID,
+		DedupeKey:   dedupeKey,
+		Payload:     marshaled,
+		State:       model.JobStateQueued,
+		MaxAttempts: q.cfg.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := q.db.DB.NewInsert().Model(job).Exec(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return job, nil
+}
+
+// Lease claims the next runnable job of one of jobTypes for workerID. It
+// returns nil when there is nothing to do.
+func (q *Queue) Lease(ctx context.Context, workerID string, jobTypes []string) (*model.Job, error) {
+	var leased *model.Job
+	err := q.db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+		// A job whose worker died on its last attempt is given up rather
+		// than leased again, as Fail would have done.
+		if _, err := tx.NewUpdate().Model((*model.Job)(nil)).
+			Set("state = ?", model.JobStateDead).
+			Set("dedupe_key = NULL").
+			Set("last_error = ?", "lease expired on the last attempt").
+			Set("lease_expires_at = NULL").
+			Set("finished_at = ?", now).
+			Set("updated_at = ?", now).
+			Where("type IN (?)", bun.In(jobTypes)).
+			Where("state = ?", model.JobStateRunning).
+			Where("lease_expires_at < ?", now).
+			Where("attempts >= max_attempts").
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		var job model.Job
+		err := tx.NewSelect().Model(&job).
+			Where("type IN (?)", bun.In(jobTypes)).
+			WhereGroup(" AND ", func(sq *bun.SelectQuery) *bun.SelectQuery {
+				return sq.
+					WhereGroup(" OR ", func(sq *bun.SelectQuery) *bun.SelectQuery {
+						return sq.Where("state = ?", model.JobStateQueued).Where("run_at <= ?", now)
+					}).
+					WhereGroup(" OR ", func(sq *bun.SelectQuery) *bun.SelectQuery {
+						return sq.Where("state = ?", model.JobStateRunning).Where("lease_expires_at < ?", now).Where("attempts < max_attempts")
+					})
+			}).
+			Order("run_at ASC").
+			Limit(1).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		expires := now.Add(q.cfg.LeaseDuration)
+		job.State = model.JobStateRunning
+		job.Attempts++
+		job.LeasedBy = workerID
+		job.LeaseExpiresAt = &expires
+		job.StartedAt = &now
+		job.FinishedAt = nil
+		job.UpdatedAt = now
+		if _, err := tx.NewUpdate().Model(&job).
+			Column("state", "attempts", "leased_by", "lease_expires_at", "started_at", "finished_at", "updated_at").
+			WherePK().
+			Exec(ctx); err != nil {
+			return err
+		}
+		leased = &job
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return leased, nil
+}
+
+// Heartbeat extends the lease of a running job. It fails when the lease was
+// lost to another worker.
+func (q *Queue) Heartbeat(ctx context.Context, job *model.Job) error {
+	expires := time.Now().Add(q.cfg.LeaseDuration)
+	res, err := q.db.DB.NewUpdate().Model((*model.Job)(nil)).
+		Set("lease_expires_at = ?", expires).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", job.ID).
+		Where("state = ?", model.JobStateRunning).
+		Where("leased_by = ?", job.LeasedBy).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.Errorf("job %d: lease lost", job.ID)
+	}
+	job.LeaseExpiresAt = &expires
+	return nil
+}
+
+// Complete marks a job as succeeded. It fails without touching the job when
+// the lease was lost to another worker.
+func (q *Queue) Complete(ctx context.Context, job *model.Job) error {
+	now := time.Now()
+	job.State = model.JobStateSucceeded
+	job.DedupeKey = nil
+	job.LastError = ""
+	job.LeaseExpiresAt = nil
+	job.FinishedAt = &now
+	job.UpdatedAt = now
+	return q.finish(ctx, job, "state", "dedupe_key", "last_error", "pages_processed", "lease_expires_at", "finished_at", "updated_at")
+}
+
+// Fail records cause and schedules a retry with exponential backoff, or moves
+// the job to the dead-letter state once it has used all of its attempts. Like
+// Complete, it fails when the lease was lost.
+func (q *Queue) Fail(ctx context.Context, job *model.Job, cause error) error {
+	now := time.Now()
+	job.LastError = cause.Error()
+	job.LeaseExpiresAt = nil
+	job.UpdatedAt = now
+	if job.Attempts >= job.MaxAttempts {
+		job.State = model.JobStateDead
+		job.DedupeKey = nil
+		job.FinishedAt = &now
+	} else {
+		job.State = model.JobStateQueued
+		job.RunAt = now.Add(q.backoff(job.Attempts))
+	}
+	return q.finish(ctx, job, "state", "dedupe_key", "last_error", "pages_processed", "run_at", "lease_expires_at", "finished_at", "updated_at")
+}
+
+// finish writes columns of a job that leaving the running state changed, as
+// long as job.LeasedBy still holds its lease.
+func (q *Queue) finish(ctx context.Context, job *model.Job, columns ...string) error {
+	res, err := q.db.DB.NewUpdate().Model(job).
+		Column(columns...).
+		WherePK().
+		Where("state = ?", model.JobStateRunning).
+		Where("leased_by = ?", job.LeasedBy).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.Errorf("job %d: lease lost", job.ID)
+	}
+	return nil
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.cfg.BackoffBase
+	for i := 1; i < attempts && d < q.cfg.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > q.cfg.BackoffMax {
+		d = q.cfg.BackoffMax
+	}
+	return d
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+)
+
+const (
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore persists crawl artifacts and other objects by key.
+// Keys use "/" as separator regardless of the backend.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the backend selected by cfg.Backend.
+func NewBlobStore(cfg *config.StorageConfig, awsCfg aws.Config) (BlobStore, error) {
+	switch cfg.Backend {
+	case BackendS3, "":
+		return NewS3Store(awsCfg, cfg.Bucket), nil
+	case BackendLocal:
+		return NewLocalStore(cfg.LocalDir)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,84 @@
+package subdomain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	// MaxLength is the longest DNS label allowed by RFC 1035.
+	MaxLength = 63
+)
+
+var (
+	ErrInvalid  = errors.New("invalid sub domain")
+	ErrReserved = errors.New("reserved sub domain")
+)
+
+// reserved names are kept for our own hosts and for names users could mistake
+// for official pages.
+var reserved = map[string]bool{
+	"www":       true,
+	"api":       true,
+	"admin":     true,
+	"app":       true,
+	"dashboard": true,
+	"console":   true,
+	"auth":      true,
+	"login":     true,
+	"signup":    true,
+	"account":   true,
+	"accounts":  true,
+	"billing":   true,
+	"support":   true,
+	"help":      true,
+	"docs":      true,
+	"status":    true,
+	"blog":      true,
+	"mail":      true,
+	"smtp":      true,
+	"ftp":       true,
+	"cdn":       true,
+	"static":    true,
+	"assets":    true,
+	"dev":       true,
+	"staging":   true,
+	"test":      true,
+	"internal":  true,
+	"root":      true,
+	"system":    true,
+	"tsumaziro": true,
+}
+
+// Normalize lowercases s and trims surrounding space so that "Acme" and
+// "acme" name the same account.
+func Normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Validate checks that a normalized sub domain is a usable DNS label: 3 to 63
+// letters, digits and hyphens, not starting or ending with a hyphen, and not
+// one of the reserved names.
+func Validate(s string) error {
+	if len(s) < MinLength || len(s) > MaxLength {
+		return fmt.Errorf("%w: must be %d to %d characters", ErrInvalid, MinLength, MaxLength)
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("%w: only lowercase letters, digits and hyphens are allowed", ErrInvalid)
+		}
+	}
+	if s[0] == '-' || s[len(s)-1] == '-' {
+		return fmt.Errorf("%w: must not start or end with a hyphen", ErrInvalid)
+	}
+	// Labels like "xn--" are reserved for internationalized domain names.
+	if len(s) >= 4 && s[2:4] == "--" {
+		return fmt.Errorf("%w: must not have hyphens in the third and fourth position", ErrInvalid)
+	}
+	if reserved[s] {
+		return ErrReserved
+	}
+	return nil
+}
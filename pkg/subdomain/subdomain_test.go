@@ -0,0 +1,42 @@
+package subdomain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"acme", nil},
+		{"acme-support", nil},
+		{"a1b", nil},
+		{strings.Repeat("a", MaxLength), nil},
+		{"ab", ErrInvalid},
+		{strings.Repeat("a", MaxLength+1), ErrInvalid},
+		{"Acme", ErrInvalid},
+		{"acme.jp", ErrInvalid},
+		{"acme_co", ErrInvalid},
+		{"アクメ", ErrInvalid},
+		{"-acme", ErrInvalid},
+		{"acme-", ErrInvalid},
+		{"xn--acme", ErrInvalid},
+		{"ab--cd", ErrInvalid},
+		{"api", ErrReserved},
+		{"tsumaziro", ErrReserved},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.in); !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("  Acme "); got != "acme" {
+		t.Errorf("Normalize = %q, want %q", got, "acme")
+	}
+}
@@ -0,0 +1,155 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/domainverify"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/subdomain"
+)
+
+// How a request was matched to its tenant.
+const (
+	SourcePath         = "path"
+	SourceSubDomain    = "sub_domain"
+	SourceCustomDomain = "custom_domain"
+)
+
+// Tenant is the account a request is served for.
+type Tenant struct {
+	ID     string
+	Source string
+	// Host is the request host the tenant was resolved from, empty for SourcePath.
+	Host    string
+	Account *model.Account
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant stored in ctx, or nil.
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(contextKey{}).(*Tenant)
+	return t
+}
+
+// Resolver maps request hosts to accounts: <sub>.<base domain> by sub domain
+// and any other host through the verified rows of the custom_domains table.
+// The API host itself serves no tenant. Custom domain lookups, including
+// misses, are cached for cfg.DomainCacheTTL so that arbitrary Host headers do
+// not each cost a query.
+type Resolver struct {
+	cfg *config.TenantConfig
+	db  *db.DB
+
+	mu    sync.Mutex
+	cache map[string]*cachedDomain
+}
+
+// cachedDomain is a custom domain lookup result. AccountID is empty when the
+// host is not a verified custom domain.
+type cachedDomain struct {
+	accountID string
+	expiresAt time.Time
+}
+
+// maxCachedDomains bounds the cache; expired entries are swept once it fills.
+const maxCachedDomains = 10000
+
+func NewResolver(cfg *config.TenantConfig, db *db.DB) *Resolver {
+	return &Resolver{cfg: cfg, db: db, cache: make(map[string]*cachedDomain)}
+}
+
+// Hostname lowercases host and strips its port and trailing dot.
+func Hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// ResolveHost returns the tenant served on host, or nil when the host does not
+// belong to a tenant. The account itself is not loaded.
+func (r *Resolver) ResolveHost(ctx context.Context, host string) (*Tenant, error) {
+	hostname := Hostname(host)
+	if hostname == "" || hostname == Hostname(r.cfg.APIHost) {
+		return nil, nil
+	}
+	if base := Hostname(r.cfg.BaseDomain); base != "" {
+		if hostname == base {
+			return nil, nil
+		}
+		if label, ok := strings.CutSuffix(hostname, "."+base); ok {
+			if strings.Contains(label, ".") || subdomain.Validate(label) != nil {
+				return nil, nil
+			}
+			return &Tenant{ID: label, Source: SourceSubDomain, Host: hostname}, nil
+		}
+	}
+
+	// Only well-formed domain names can have been verified as custom
+	// domains, so anything else never reaches the database.
+	if domainverify.ValidateHost(hostname) != nil || net.ParseIP(hostname) != nil {
+		return nil, nil
+	}
+	accountID, err := r.customDomain(ctx, hostname)
+	if err != nil || accountID == "" {
+		return nil, err
+	}
+	return &Tenant{ID: accountID, Source: SourceCustomDomain, Host: hostname}, nil
+}
+
+// Forget drops the cached lookup of host, so that a custom domain that was
+// deleted or verified takes effect at once rather than after DomainCacheTTL.
+func (r *Resolver) Forget(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, Hostname(host))
+}
+
+// customDomain returns the account that verified hostname, or "" when there is
+// none.
+func (r *Resolver) customDomain(ctx context.Context, hostname string) (string, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.cache[hostname]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.accountID, nil
+	}
+
+	var domain model.CustomDomain
+	err := r.db.DB.NewSelect().Model(&domain).
+		Column("account_id").
+		Where("verified_host = ?", hostname).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cache) >= maxCachedDomains {
+		for host, entry := range r.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(r.cache, host)
+			}
+		}
+		if len(r.cache) >= maxCachedDomains {
+			clear(r.cache)
+		}
+	}
+	r.cache[hostname] = &cachedDomain{accountID: domain.AccountID, expiresAt: now.Add(r.cfg.DomainCacheTTL)}
+	return domain.AccountID, nil
+}
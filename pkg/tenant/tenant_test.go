@@ -0,0 +1,100 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+)
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Acme.Example.com", "acme.example.com"},
+		{"acme.example.com:8080", "acme.example.com"},
+		{"acme.example.com.", "acme.example.com"},
+		{"[::1]:8080", "::1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Hostname(tt.in); got != tt.want {
+			t.Errorf("Hostname(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestResolveHostWithoutDB covers the hosts that must be resolved, or turned
+// away, without a query. The resolver has no database, so any host that would
+// reach it panics.
+func TestResolveHostWithoutDB(t *testing.T) {
+	resolver := NewResolver(&config.TenantConfig{
+		APIHost:        "api.tsumaziro.example",
+		BaseDomain:     "tsumaziro.example",
+		DomainCacheTTL: time.Minute,
+	}, nil)
+	tests := []struct {
+		host string
+		id   string
+	}{
+		{"acme.tsumaziro.example", "acme"},
+		{"ACME.tsumaziro.example:443", "acme"},
+		{"api.tsumaziro.example", ""},
+		{"tsumaziro.example", ""},
+		{"a.b.tsumaziro.example", ""},
+		{"www.tsumaziro.example", ""},
+		{"-bad.tsumaziro.example", ""},
+		{"", ""},
+		{"localhost", ""},
+		{"localhost:8080", ""},
+		{"127.0.0.1", ""},
+		{"10.0.0.1:80", ""},
+		{"[::1]:8080", ""},
+		{"under_score.example.com", ""},
+		{"bad..example.com", ""},
+	}
+	for _, tt := range tests {
+		got, err := resolver.ResolveHost(context.Background(), tt.host)
+		if err != nil {
+			t.Errorf("ResolveHost(%q): %v", tt.host, err)
+			continue
+		}
+		switch {
+		case tt.id == "" && got != nil:
+			t.Errorf("ResolveHost(%q) = %+v, want nil", tt.host, got)
+		case tt.id != "" && (got == nil || got.ID != tt.id || got.Source != SourceSubDomain):
+			t.Errorf("ResolveHost(%q) = %+v, want sub domain %q", tt.host, got, tt.id)
+		}
+	}
+}
+
+func TestResolveHostCache(t *testing.T) {
+	resolver := NewResolver(&config.TenantConfig{DomainCacheTTL: time.Minute}, nil)
+	resolver.cache["help.acme.com"] = &cachedDomain{accountID: "acme", expiresAt: time.Now().Add(time.Minute)}
+	resolver.cache["unknown.example.com"] = &cachedDomain{expiresAt: time.Now().Add(time.Minute)}
+
+	got, err := resolver.ResolveHost(context.Background(), "Help.Acme.com")
+	if err != nil || got == nil || got.ID != "acme" || got.Source != SourceCustomDomain || got.Host != "help.acme.com" {
+		t.Errorf("ResolveHost(cached) = %+v, %v, want custom domain of acme", got, err)
+	}
+	got, err = resolver.ResolveHost(context.Background(), "unknown.example.com")
+	if err != nil || got != nil {
+		t.Errorf("ResolveHost(cached miss) = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestForget(t *testing.T) {
+	resolver := NewResolver(&config.TenantConfig{DomainCacheTTL: time.Minute}, nil)
+	resolver.cache["help.acme.com"] = &cachedDomain{accountID: "acme", expiresAt: time.Now().Add(time.Minute)}
+	resolver.cache["help.other.com"] = &cachedDomain{accountID: "other", expiresAt: time.Now().Add(time.Minute)}
+
+	resolver.Forget("Help.Acme.com")
+	if _, ok := resolver.cache["help.acme.com"]; ok {
+		t.Error("Forget kept help.acme.com cached")
+	}
+	if _, ok := resolver.cache["help.other.com"]; !ok {
+		t.Error("Forget dropped help.other.com")
+	}
+}
@@ -0,0 +1,18 @@
+package token
+
+import "testing"
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, tt := range tests {
+		if got := Hash(tt.in); got != tt.want {
+			t.Errorf("Hash(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
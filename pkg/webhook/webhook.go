@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+)
+
+const SignatureHeader = "X-Tsumaziro-Signature"
+
+type Event struct {
+	Type      string    `json:"type"`
+	AccountID string    `json:"account_id"`
+	Data      any       `json:"data"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+type Client struct {
+	httpClient *http.Client
+}
+
+func NewClient(cfg *config.WebhookConfig) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// GenerateSecret returns a new signing secret for an account. Each account
+// has its own so that it can verify its webhooks without being able to forge
+// those of other accounts.
+func GenerateSecret() (string, error) {
+	return token.Generate(32)
+}
+
+// Send posts the event as JSON to url. When secret is set the body is signed
+// with HMAC-SHA256 and the hex digest is sent in SignatureHeader.
+func (c *Client) Send(ctx context.Context, url, secret string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with status %d", url, resp.StatusCode)
+	}
+	return nil
+}
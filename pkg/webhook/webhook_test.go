@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/config"
+)
+
+func TestSendSignsWithAccountSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"signed", "account-secret"},
+		{"unsigned", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(SignatureHeader)
+			}))
+			defer server.Close()
+
+			client := NewClient(&config.WebhookConfig{Timeout: 5 * time.Second})
+			if err := client.Send(context.Background(), server.URL, tt.secret, Event{Type: "test", AccountID: "acme"}); err != nil {
+				t.Fatal(err)
+			}
+			want := ""
+			if tt.secret != "" {
+				mac := hmac.New(sha256.New, []byte(tt.secret))
+				mac.Write(body)
+				want = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			}
+			if signature != want {
+				t.Errorf("signature = %q, want %q", signature, want)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 || a == b {
+		t.Errorf("GenerateSecret = %q, %q, want two distinct 64 character secrets", a, b)
+	}
+}
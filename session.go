@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/yamato0211/tsumaziro-faq-server/db/model"
+	connector "github.com/yamato0211/tsumaziro-faq-server/pkg/db"
+	"github.com/yamato0211/tsumaziro-faq-server/pkg/token"
+)
+
+// resolveSession returns sessionID when it belongs to the account, or starts a
+// new chat session when sessionID is empty.
+func resolveSession(ctx context.Context, db *connector.DB, accountID, sessionID string) (string, error) {
+	if sessionID != "" {
+		exists, err := db.DB.NewSelect().Model((*model.ChatSession)(nil)).Where("id = ?", sessionID).Where("account_id = ?", accountID).Exists(ctx)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if !exists {
+			return "", errors.Errorf("session %s does not exist", sessionID)
+		}
+		return sessionID, nil
+	}
+
+	id, err := token.Generate(16)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	session := &model.ChatSession{
+		ID:        id,
+		AccountID: accountID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if _, err := db.DB.NewInsert().Model(session).Exec(ctx); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return id, nil
+}
+
+func saveMessage(ctx context.Context, db *connector.DB, sessionID, role, text string) error {
+	message := &model.ChatMessage{
+		SessionID: sessionID,
+		Role:      role,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	if _, err := db.DB.NewInsert().Model(message).Exec(ctx); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}